@@ -6,11 +6,18 @@ import (
 	"fmt"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/icinga/icinga-testing/services"
 	"github.com/icinga/icinga-testing/utils"
+	"github.com/icinga/icinga-testing/utils/faketime"
+	"github.com/icinga/icinga-testing/utils/pki"
 	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,6 +29,7 @@ type dockerCreator struct {
 	dockerNetworkId     string
 	containerNamePrefix string
 	containerCounter    uint32
+	ca                  *pki.CA
 
 	runningMutex sync.Mutex
 	running      map[*dockerInstance]struct{}
@@ -34,17 +42,27 @@ func NewDockerCreator(
 	dockerClient *client.Client,
 	containerNamePrefix string,
 	dockerNetworkId string,
+	ca *pki.CA,
 ) Creator {
 	return &dockerCreator{
 		logger:              logger.With(zap.Bool("icinga2", true)),
 		dockerClient:        dockerClient,
 		dockerNetworkId:     dockerNetworkId,
 		containerNamePrefix: containerNamePrefix,
+		ca:                  ca,
 		running:             make(map[*dockerInstance]struct{}),
 	}
 }
 
-func (i *dockerCreator) CreateIcinga2(name string) services.Icinga2Base {
+func (i *dockerCreator) CreateIcinga2(name string, options ...services.Icinga2Option) services.Icinga2Base {
+	n := &dockerInstance{
+		icinga2Docker: i,
+	}
+	node := &services.Icinga2{Icinga2Base: n}
+	for _, option := range options {
+		option(node)
+	}
+
 	containerName := fmt.Sprintf("%s-%d-%s", i.containerNamePrefix, atomic.AddUint32(&i.containerCounter, 1), name)
 	logger := i.logger.With(zap.String("container-name", containerName))
 
@@ -59,11 +77,39 @@ func (i *dockerCreator) CreateIcinga2(name string) services.Icinga2Base {
 		panic(err)
 	}
 
+	env := []string{"ICINGA_MASTER=1", "ICINGA_CN=" + name}
+	hostConfig := &container.HostConfig{}
+	if clock := node.Clock(); clock != nil {
+		key := "ICINGA_TESTING_LIBFAKETIME"
+		libfaketime, ok := os.LookupEnv(key)
+		if !ok {
+			panic(fmt.Errorf("environment variable %s must be set", key))
+		}
+		libfaketime, err = filepath.Abs(libfaketime)
+		if err != nil {
+			panic(err)
+		}
+
+		n.clock = clock
+		env = append(env, clock.Env()...)
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   libfaketime,
+			Target:   faketime.ContainerLibrary,
+			ReadOnly: true,
+		}, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   clock.Dir(),
+			Target:   faketime.ContainerDir,
+			ReadOnly: true,
+		})
+	}
+
 	cont, err := i.dockerClient.ContainerCreate(context.Background(), &container.Config{
 		Image:    dockerImage,
 		Hostname: name,
-		Env:      []string{"ICINGA_MASTER=1"},
-	}, nil, &network.NetworkingConfig{
+		Env:      env,
+	}, hostConfig, &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
 			networkName: {
 				NetworkID: i.dockerNetworkId,
@@ -78,11 +124,13 @@ func (i *dockerCreator) CreateIcinga2(name string) services.Icinga2Base {
 
 	err = utils.ForwardDockerContainerOutput(context.Background(), i.dockerClient, cont.ID,
 		false, utils.NewLineWriter(func(line []byte) {
+			n.output.Add(line)
 			logger.Debug("container output", zap.ByteString("line", line))
 		}))
 	if err != nil {
 		logger.Fatal("failed to attach to container output", zap.Error(err))
 	}
+	n.watch = utils.DockerWatchContainer(i.dockerClient, cont.ID, &n.output)
 
 	err = i.dockerClient.ContainerStart(context.Background(), cont.ID, types.ContainerStartOptions{})
 	if err != nil {
@@ -90,16 +138,14 @@ func (i *dockerCreator) CreateIcinga2(name string) services.Icinga2Base {
 	}
 	logger.Debug("started container")
 
-	n := &dockerInstance{
-		info: info{
-			host: utils.MustString(utils.DockerContainerAddress(context.Background(), i.dockerClient, cont.ID)),
-			port: "5665",
-		},
-		icinga2Docker: i,
-		logger:        logger,
-		containerId:   cont.ID,
-		containerName: containerName,
+	n.info = info{
+		host: utils.MustString(utils.DockerContainerAddress(context.Background(), i.dockerClient, cont.ID)),
+		port: "5665",
 	}
+	n.name = name
+	n.logger = logger
+	n.containerId = cont.ID
+	n.containerName = containerName
 
 	for attempt := 1; ; attempt++ {
 		time.Sleep(100 * time.Millisecond)
@@ -112,6 +158,12 @@ func (i *dockerCreator) CreateIcinga2(name string) services.Icinga2Base {
 	}
 
 	WriteInitialConfig(n)
+
+	// Replace the certificates created by the container on startup with ones issued by the CA of the creator, so that
+	// clients can verify the certificate of the node and authenticate using certificates from the same CA.
+	services.Icinga2{Icinga2Base: n}.InstallCA(i.ca)
+	n.ca = i.ca
+
 	err = services.Icinga2{Icinga2Base: n}.Reload()
 	if err != nil {
 		logger.Fatal("failed initial reload of icinga2", zap.Error(err))
@@ -139,14 +191,31 @@ func (i *dockerCreator) Cleanup() {
 
 type dockerInstance struct {
 	info
+	name          string
+	ca            *pki.CA
+	clock         *faketime.Clock
 	icinga2Docker *dockerCreator
 	logger        *zap.Logger
 	containerId   string
 	containerName string
+	output        utils.LineRecorder
+	watch         *utils.DockerContainerWatch
 }
 
 var _ services.Icinga2Base = (*dockerInstance)(nil)
 
+func (n *dockerInstance) NodeName() string {
+	return n.name
+}
+
+func (n *dockerInstance) CA() *pki.CA {
+	return n.ca
+}
+
+func (n *dockerInstance) Clock() *faketime.Clock {
+	return n.clock
+}
+
 func (n *dockerInstance) TriggerReload() {
 	err := n.icinga2Docker.dockerClient.ContainerKill(context.Background(), n.containerId, "HUP")
 	if err != nil {
@@ -185,6 +254,50 @@ func (n *dockerInstance) DeleteConfigGlob(glob string) {
 	}
 }
 
+// containerPath returns the absolute path within the container for a path given relative to its root directory, as
+// done for the functions of services.Icinga2Base.
+func containerPath(p string) string {
+	return path.Join("/", p)
+}
+
+func (n *dockerInstance) ReadFile(file string) ([]byte, error) {
+	return utils.DockerReadFile(context.Background(), n.icinga2Docker.dockerClient, n.containerId, containerPath(file))
+}
+
+func (n *dockerInstance) ListDir(dir string) ([]utils.DockerFileInfo, error) {
+	return utils.DockerListDir(context.Background(), n.icinga2Docker.dockerClient, n.containerId, containerPath(dir))
+}
+
+func (n *dockerInstance) CopyTo(dir string, tarball io.Reader) error {
+	return utils.DockerCopyToContainer(context.Background(), n.icinga2Docker.dockerClient, n.containerId,
+		containerPath(dir), tarball)
+}
+
+func (n *dockerInstance) CopyFrom(p string) (io.ReadCloser, error) {
+	return utils.DockerCopyFromContainer(context.Background(), n.icinga2Docker.dockerClient, n.containerId,
+		containerPath(p))
+}
+
+func (n *dockerInstance) Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error) {
+	return utils.DockerExecResult(ctx, n.icinga2Docker.dockerClient, n.logger, n.containerId, cmd, stdin)
+}
+
+func (n *dockerInstance) ExitStatus() (utils.ExitStatus, bool) {
+	return n.watch.ExitStatus()
+}
+
+func (n *dockerInstance) Exited() <-chan struct{} {
+	return n.watch.Done()
+}
+
+func (n *dockerInstance) Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error) {
+	res, err := n.watch.Stop(ctx, timeout)
+	if err == nil {
+		n.logger.Debug("stopped container", zap.Int("exit-code", res.ExitCode), zap.Duration("duration", res.Duration))
+	}
+	return res, err
+}
+
 func (n *dockerInstance) EnableIcingaDb(redis services.RedisServerBase) {
 	services.Icinga2{Icinga2Base: n}.WriteIcingaDbConf(redis)
 }
@@ -194,6 +307,7 @@ func (n *dockerInstance) Cleanup() {
 	delete(n.icinga2Docker.running, n)
 	n.icinga2Docker.runningMutex.Unlock()
 
+	n.watch.Expect()
 	err := n.icinga2Docker.dockerClient.ContainerRemove(context.Background(), n.containerId, types.ContainerRemoveOptions{
 		Force:         true,
 		RemoveVolumes: true,
@@ -6,7 +6,7 @@ import (
 )
 
 type Creator interface {
-	CreateIcinga2(name string) services.Icinga2Base
+	CreateIcinga2(name string, options ...services.Icinga2Option) services.Icinga2Base
 	Cleanup()
 }
 
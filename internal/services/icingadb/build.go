@@ -0,0 +1,327 @@
+package icingadb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/icinga/icinga-testing/utils"
+	"go.uber.org/zap"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Builders supported by BuildBinary.
+const (
+	// BuilderDocker builds Icinga DB in a container using the ICINGA_TESTING_GOLANG_IMAGE image (default:
+	// "golang:latest"). The Go module and build caches are kept in Docker volumes to speed up subsequent builds.
+	BuilderDocker = "docker"
+
+	// BuilderHost builds Icinga DB using the Go toolchain of the host.
+	BuilderHost = "host"
+)
+
+// BuildOptions configures how BuildBinary builds Icinga DB.
+type BuildOptions struct {
+	// Cover builds the binary using "go build -cover" for collecting coverage data of all Icinga DB packages.
+	Cover bool
+
+	// Race builds the binary using "go build -race". The race detector requires cgo, so unlike other builds, the
+	// binary is linked against the C library of the builder and has to run in a compatible image, see
+	// NewDockerBinaryCreator.
+	Race bool
+
+	// Faketime builds the binary with a time package whose Now function adds the offset of a faketime.Clock to the
+	// real time if FAKETIME_TIMESTAMP_FILE is set, see services.WithIcingaDbClock. Other functions like Sleep or timers
+	// keep using the real time.
+	Faketime bool
+}
+
+// BuildBinary builds cmd/icingadb from the Icinga DB source checkout in sourceDir and returns the path of the binary.
+// Unless options.Race is set, it is built with CGO_ENABLED=0. Binaries are cached in the user cache directory by the
+// hash of the source tree and the options, so the build only runs if the source changed since the last build.
+func BuildBinary(
+	ctx context.Context, logger *zap.Logger, dockerClient *client.Client, sourceDir string, builder string,
+	options BuildOptions,
+) (string, error) {
+	sourceDir, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := sourceTreeHash(sourceDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash icingadb source tree %q: %w", sourceDir, err)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := "icingadb"
+	if options.Cover {
+		name += "-cover"
+	}
+	if options.Race {
+		name += "-race"
+	}
+	if options.Faketime {
+		name += "-faketime"
+	}
+	binary := filepath.Join(cacheDir, "icinga-testing", "icingadb", hash, name)
+	logger = logger.With(zap.String("source", sourceDir), zap.String("binary", binary))
+
+	if _, err := os.Stat(binary); err == nil {
+		logger.Debug("using cached icingadb binary")
+		return binary, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return "", err
+	}
+
+	// Build into a temporary file first so that an interrupted build does not leave a broken binary in the cache. Each
+	// build uses its own file, so concurrent builds of the same source, for example by multiple test packages, only
+	// share the final rename.
+	f, err := os.CreateTemp(filepath.Dir(binary), name+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	_ = f.Close()
+	logger.Info("building icingadb", zap.String("builder", builder))
+
+	overlayDir := ""
+	if options.Faketime {
+		overlayDir, err = faketimeOverlayDir()
+		if err != nil {
+			_ = os.Remove(tmp)
+			return "", err
+		}
+		defer func() { _ = os.RemoveAll(overlayDir) }()
+	}
+
+	switch builder {
+	case BuilderDocker:
+		err = buildBinaryDocker(ctx, logger, dockerClient, sourceDir, tmp, overlayDir, options)
+	case BuilderHost:
+		err = buildBinaryHost(ctx, sourceDir, tmp, overlayDir, options)
+	default:
+		err = fmt.Errorf("unknown builder %q", builder)
+	}
+	if err == nil {
+		// os.CreateTemp creates the file with mode 0600, which the builders keep.
+		err = os.Chmod(tmp, 0755)
+	}
+	if err == nil {
+		err = os.Rename(tmp, binary)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	logger.Info("built icingadb")
+
+	return binary, nil
+}
+
+// buildArgs returns the arguments for go build in addition to the output file and package.
+func buildArgs(options BuildOptions) []string {
+	// The source may not be a Git checkout or be owned by another user, which lets VCS stamping fail.
+	args := []string{"-buildvcs=false"}
+	if options.Cover {
+		args = append(args, "-cover", "-coverpkg=./...")
+	}
+	if options.Race {
+		args = append(args, "-race")
+	}
+	return args
+}
+
+// buildCommand returns the command running go build with the given arguments. If overlayDir is not empty, the
+// command adds faketime support to the time package of the builder's Go installation, see faketimeOverlayScript.
+func buildCommand(overlayDir string, args []string) []string {
+	if overlayDir == "" {
+		return append([]string{"go", "build"}, args...)
+	}
+	return append([]string{"sh", "-c", faketimeOverlayScript, "sh", overlayDir}, args...)
+}
+
+// buildEnv returns the environment variables for go build in addition to those of the builder.
+func buildEnv(options BuildOptions) []string {
+	if options.Race {
+		return []string{"CGO_ENABLED=1"}
+	}
+	return []string{"CGO_ENABLED=0"}
+}
+
+// buildBinaryHost builds cmd/icingadb using the Go toolchain of the host.
+func buildBinaryHost(
+	ctx context.Context, sourceDir string, output string, overlayDir string, options BuildOptions,
+) error {
+	args := buildCommand(overlayDir, append(buildArgs(options), "-o", output, "./cmd/icingadb"))
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = sourceDir
+	cmd.Env = append(append(os.Environ(), buildEnv(options)...), "GOOS=linux", "GOARCH="+runtime.GOARCH)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("building icingadb failed: %w\n%s", err, out)
+	}
+
+	return nil
+}
+
+// buildBinaryDocker builds cmd/icingadb in a Go builder container and copies the binary out of it.
+func buildBinaryDocker(
+	ctx context.Context, logger *zap.Logger, dockerClient *client.Client,
+	sourceDir string, output string, overlayDir string, options BuildOptions,
+) error {
+	image := utils.GetEnvDefault("ICINGA_TESTING_GOLANG_IMAGE", "golang:latest")
+	if err := utils.DockerImagePull(ctx, logger, dockerClient, image, false); err != nil {
+		return err
+	}
+
+	mounts := []mount.Mount{{
+		Type:     mount.TypeBind,
+		Source:   sourceDir,
+		Target:   "/src",
+		ReadOnly: true,
+	}, {
+		Type:   mount.TypeVolume,
+		Source: "icinga-testing-go-mod-cache",
+		Target: "/go/pkg/mod",
+	}, {
+		Type:   mount.TypeVolume,
+		Source: "icinga-testing-go-build-cache",
+		Target: "/root/.cache/go-build",
+	}}
+	containerOverlayDir := ""
+	if overlayDir != "" {
+		containerOverlayDir = "/overlay"
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: overlayDir,
+			Target: containerOverlayDir,
+		})
+	}
+
+	cmd := buildCommand(containerOverlayDir, append(buildArgs(options), "-o", "/out/icingadb", "./cmd/icingadb"))
+	cont, err := dockerClient.ContainerCreate(ctx, &container.Config{
+		Image:      image,
+		Cmd:        cmd,
+		Env:        buildEnv(options),
+		WorkingDir: "/src",
+	}, &container.HostConfig{
+		Mounts: mounts,
+	}, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create builder container: %w", err)
+	}
+	defer func() {
+		err := dockerClient.ContainerRemove(context.Background(), cont.ID, types.ContainerRemoveOptions{
+			Force:         true,
+			RemoveVolumes: true,
+		})
+		if err != nil {
+			logger.Error("failed to remove builder container", zap.Error(err))
+		}
+	}()
+
+	waitCh, errCh := dockerClient.ContainerWait(ctx, cont.ID, container.WaitConditionNextExit)
+
+	if err := dockerClient.ContainerStart(ctx, cont.ID, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("failed to start builder container: %w", err)
+	}
+
+	var exitCode int64
+	select {
+	case res := <-waitCh:
+		if res.Error != nil {
+			return fmt.Errorf("failed to wait for builder container: %s", res.Error.Message)
+		}
+		exitCode = res.StatusCode
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for builder container: %w", err)
+	}
+
+	if exitCode != 0 {
+		var out bytes.Buffer
+		if logs, err := dockerClient.ContainerLogs(ctx, cont.ID, types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+		}); err == nil {
+			_, _ = stdcopy.StdCopy(&out, &out, logs)
+			_ = logs.Close()
+		}
+		return fmt.Errorf("building icingadb failed with exit code %d:\n%s", exitCode, out.String())
+	}
+
+	binary, err := utils.DockerReadFile(ctx, dockerClient, cont.ID, "/out/icingadb")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, binary, 0755)
+}
+
+// sourceTreeHash returns a hash over the paths, modes and contents of all files within dir, ignoring .git
+// directories.
+func sourceTreeHash(dir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(rel), info.Mode())
+
+		switch {
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, f)
+			_ = f.Close()
+			if err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(h, target)
+		}
+		_, _ = h.Write([]byte{0})
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
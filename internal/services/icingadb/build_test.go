@@ -0,0 +1,47 @@
+package icingadb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceTreeHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := sourceTreeHash(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	write("go.mod", "module github.com/icinga/icingadb")
+	write("cmd/icingadb/main.go", "package main")
+	initial := hash()
+
+	if h := hash(); h != initial {
+		t.Errorf("sourceTreeHash() = %q, want %q for unchanged tree", h, initial)
+	}
+
+	write(".git/HEAD", "ref: refs/heads/main")
+	if h := hash(); h != initial {
+		t.Errorf("sourceTreeHash() = %q, want %q after changing .git", h, initial)
+	}
+
+	write("cmd/icingadb/main.go", "package main // changed")
+	if h := hash(); h == initial {
+		t.Errorf("sourceTreeHash() = %q, want a different hash after changing a file", h)
+	}
+}
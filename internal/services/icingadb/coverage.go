@@ -0,0 +1,94 @@
+package icingadb
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// coverageContainerDir is the directory GOCOVERDIR points to within the container.
+const coverageContainerDir = "/coverage"
+
+// coverage collects the coverage data written by Icinga DB binaries built using "go build -cover" and merges it into
+// a single profile. Each instance writes to its own directory, as all of them share the same PID within their
+// container, which would result in conflicting file names otherwise.
+type coverage struct {
+	dir     string
+	profile string
+	counter uint32
+}
+
+// newCoverage creates a temporary directory for collecting the coverage data that is merged into profile by merge.
+func newCoverage(profile string) (*coverage, error) {
+	profile, err := filepath.Abs(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "icinga-testing-icingadb-coverage-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &coverage{dir: dir, profile: profile}, nil
+}
+
+// instanceDir creates a new directory for the coverage data of an instance.
+func (c *coverage) instanceDir() (string, error) {
+	dir := filepath.Join(c.dir, fmt.Sprintf("%d", atomic.AddUint32(&c.counter, 1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	// Container images may run Icinga DB as an unprivileged user that has to be able to write to the directory.
+	if err := os.Chmod(dir, 0777); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// merge merges the coverage data of all instances into a profile in the text format understood by "go tool cover"
+// using "go tool covdata" and removes the temporary directory afterwards.
+func (c *coverage) merge(ctx context.Context, logger *zap.Logger) error {
+	defer func() {
+		if err := os.RemoveAll(c.dir); err != nil {
+			logger.Error("failed to remove coverage directory", zap.String("dir", c.dir), zap.Error(err))
+		}
+	}()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	var inputs []string
+	for _, e := range entries {
+		dir := filepath.Join(c.dir, e.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		// Instances that did not shut down gracefully or were not built with -cover leave an empty directory.
+		if len(files) > 0 {
+			inputs = append(inputs, dir)
+		}
+	}
+
+	if len(inputs) == 0 {
+		logger.Warn("no icingadb coverage data was written, was the binary built using go build -cover?")
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "tool", "covdata", "textfmt",
+		"-i="+strings.Join(inputs, ","), "-o="+c.profile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("merging icingadb coverage data failed: %w\n%s", err, out)
+	}
+	logger.Info("wrote icingadb coverage profile", zap.String("profile", c.profile), zap.Int("instances", len(inputs)))
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package icingadb
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/icinga/icinga-testing/services"
+	"github.com/icinga/icinga-testing/utils"
+	"github.com/icinga/icinga-testing/utils/faketime"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dockerCreator contains the parts shared by the creators starting Icinga DB in a Docker container.
+type dockerCreator struct {
+	logger              *zap.Logger
+	dockerClient        *client.Client
+	dockerNetworkId     string
+	containerNamePrefix string
+	containerCounter    uint32
+
+	// coverage is set if the instances write coverage data, see newCoverage.
+	coverage *coverage
+
+	// faketimeSupported is set if the binary was built using BuildOptions.Faketime, so that services.WithIcingaDbClock
+	// can be used.
+	faketimeSupported bool
+
+	runningMutex sync.Mutex
+	running      map[*dockerInstance]struct{}
+}
+
+func newDockerCreator(
+	logger *zap.Logger, dockerClient *client.Client, containerNamePrefix string, dockerNetworkId string,
+) dockerCreator {
+	return dockerCreator{
+		logger:              logger.With(zap.Bool("icingadb", true)),
+		dockerClient:        dockerClient,
+		dockerNetworkId:     dockerNetworkId,
+		containerNamePrefix: containerNamePrefix,
+		running:             make(map[*dockerInstance]struct{}),
+	}
+}
+
+// dockerStopTimeout is how long an instance is given to shut down after SIGTERM before it is killed.
+const dockerStopTimeout = 30 * time.Second
+
+// dockerContainerSpec describes how to start Icinga DB within a container. The rendered config file is mounted to
+// /icingadb.yml in addition to the given mounts.
+type dockerContainerSpec struct {
+	image      string
+	entrypoint []string
+	env        []string
+	mounts     []mount.Mount
+}
+
+// createInstance starts an Icinga DB instance in a new container. The spec is obtained from the IcingaDb with all
+// options applied.
+func (i *dockerCreator) createInstance(
+	redis services.RedisServerBase,
+	rdb services.RelationalDatabase,
+	spec func(idb *services.IcingaDb) dockerContainerSpec,
+	options ...services.IcingaDbOption,
+) services.IcingaDbBase {
+	inst := &dockerInstance{
+		info: info{
+			redis: redis,
+			rdb:   rdb,
+		},
+		logger:  i.logger,
+		creator: i,
+	}
+
+	configFile, err := ioutil.TempFile("", "icingadb.yml")
+	if err != nil {
+		panic(err)
+	}
+	idb := &services.IcingaDb{IcingaDbBase: inst}
+	for _, option := range options {
+		option(idb)
+	}
+	if err = idb.WriteConfig(configFile); err != nil {
+		panic(err)
+	}
+	inst.configFileName = configFile.Name()
+	err = configFile.Close()
+	if err != nil {
+		panic(err)
+	}
+	// Container images may run Icinga DB as an unprivileged user that has to be able to read the config.
+	err = os.Chmod(inst.configFileName, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	s := spec(idb)
+
+	if clock := idb.Clock(); clock != nil {
+		if !i.faketimeSupported {
+			panic("services.WithIcingaDbClock requires an icingadb binary built from ICINGA_TESTING_ICINGADB_SOURCE")
+		}
+
+		inst.clock = clock
+		s.env = append(s.env, clock.GoEnv()...)
+		s.mounts = append(s.mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   clock.Dir(),
+			Target:   faketime.ContainerDir,
+			ReadOnly: true,
+		})
+	}
+
+	if i.coverage != nil {
+		dir, err := i.coverage.instanceDir()
+		if err != nil {
+			panic(err)
+		}
+		s.env = append(s.env, "GOCOVERDIR="+coverageContainerDir)
+		s.mounts = append(s.mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: dir,
+			Target: coverageContainerDir,
+		})
+		// Coverage data is only written if Icinga DB exits normally.
+		inst.stopGracefully = true
+	}
+
+	containerName := fmt.Sprintf("%s-%d", i.containerNamePrefix, atomic.AddUint32(&i.containerCounter, 1))
+	inst.logger = inst.logger.With(zap.String("container-name", containerName))
+	networkName, err := utils.DockerNetworkName(context.Background(), i.dockerClient, i.dockerNetworkId)
+	if err != nil {
+		panic(err)
+	}
+
+	err = utils.DockerImagePull(context.Background(), inst.logger, i.dockerClient, s.image, false)
+	if err != nil {
+		panic(err)
+	}
+
+	cont, err := i.dockerClient.ContainerCreate(context.Background(), &container.Config{
+		Image:      s.image,
+		Entrypoint: s.entrypoint,
+		Env:        s.env,
+		Cmd:        []string{"--config", "/icingadb.yml"},
+	}, &container.HostConfig{
+		Mounts: append(s.mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   inst.configFileName,
+			Target:   "/icingadb.yml",
+			ReadOnly: true,
+		}),
+	}, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			networkName: {
+				NetworkID: i.dockerNetworkId,
+			},
+		},
+	}, nil, containerName)
+	if err != nil {
+		inst.logger.Fatal("failed to create icingadb container", zap.Error(err))
+	}
+	inst.containerId = cont.ID
+	inst.logger = inst.logger.With(zap.String("container-id", cont.ID))
+	inst.logger.Debug("created container", zap.String("image", s.image))
+
+	err = utils.ForwardDockerContainerOutput(context.Background(), i.dockerClient, cont.ID,
+		false, utils.NewLineWriter(func(line []byte) {
+			inst.output.Add(line)
+			inst.addLog(line)
+			inst.logger.Debug("container output",
+				zap.ByteString("line", line))
+		}))
+	if err != nil {
+		inst.logger.Fatal("failed to attach to container output",
+			zap.Error(err))
+	}
+
+	inst.watch = utils.DockerWatchContainer(i.dockerClient, cont.ID, &inst.output)
+
+	err = i.dockerClient.ContainerStart(context.Background(), cont.ID, types.ContainerStartOptions{})
+	if err != nil {
+		inst.logger.Fatal("failed to start container", zap.Error(err))
+	}
+	inst.logger.Debug("started container")
+
+	i.runningMutex.Lock()
+	i.running[inst] = struct{}{}
+	i.runningMutex.Unlock()
+
+	return inst
+}
+
+func (i *dockerCreator) Cleanup() {
+	i.runningMutex.Lock()
+	instances := make([]*dockerInstance, 0, len(i.running))
+	for inst := range i.running {
+		instances = append(instances, inst)
+	}
+	i.runningMutex.Unlock()
+
+	for _, inst := range instances {
+		inst.Cleanup()
+	}
+
+	if i.coverage != nil {
+		if err := i.coverage.merge(context.Background(), i.logger); err != nil {
+			i.logger.Error("failed to merge icingadb coverage data", zap.Error(err))
+		}
+	}
+}
+
+type dockerInstance struct {
+	info
+	creator        *dockerCreator
+	logger         *zap.Logger
+	containerId    string
+	configFileName string
+	clock          *faketime.Clock
+	output         utils.LineRecorder
+	logsMutex      sync.Mutex
+	logs           []services.IcingaDbLogEntry
+	watch          *utils.DockerContainerWatch
+	paused         atomic.Bool
+	stopGracefully bool
+}
+
+var _ services.IcingaDbBase = (*dockerInstance)(nil)
+
+func (i *dockerInstance) Output() []string {
+	return i.output.Lines()
+}
+
+func (i *dockerInstance) OutputSince(n int) ([]string, int) {
+	return i.output.Since(n)
+}
+
+func (i *dockerInstance) Clock() *faketime.Clock {
+	return i.clock
+}
+
+func (i *dockerInstance) LogsSince(n int) ([]services.IcingaDbLogEntry, int) {
+	i.logsMutex.Lock()
+	defer i.logsMutex.Unlock()
+
+	if n >= len(i.logs) {
+		return nil, len(i.logs)
+	}
+	return append([]services.IcingaDbLogEntry(nil), i.logs[n:]...), len(i.logs)
+}
+
+// addLog parses a line of output and records the result for LogsSince.
+func (i *dockerInstance) addLog(line []byte) {
+	e, _ := services.ParseIcingaDbLogLine(string(line))
+
+	i.logsMutex.Lock()
+	defer i.logsMutex.Unlock()
+
+	i.logs = append(i.logs, e)
+}
+
+func (i *dockerInstance) ExitStatus() (utils.ExitStatus, bool) {
+	return i.watch.ExitStatus()
+}
+
+func (i *dockerInstance) Exited() <-chan struct{} {
+	return i.watch.Done()
+}
+
+func (i *dockerInstance) Kill() error {
+	i.watch.Expect()
+	err := i.creator.dockerClient.ContainerKill(context.Background(), i.containerId, "SIGKILL")
+	if err != nil {
+		return err
+	}
+	i.logger.Debug("killed container")
+	return nil
+}
+
+func (i *dockerInstance) Pause() error {
+	err := i.creator.dockerClient.ContainerPause(context.Background(), i.containerId)
+	if err != nil {
+		return err
+	}
+	i.paused.Store(true)
+	i.logger.Debug("paused container")
+	return nil
+}
+
+func (i *dockerInstance) Unpause() error {
+	err := i.creator.dockerClient.ContainerUnpause(context.Background(), i.containerId)
+	if err != nil {
+		return err
+	}
+	i.paused.Store(false)
+	i.logger.Debug("unpaused container")
+	return nil
+}
+
+func (i *dockerInstance) Paused() bool {
+	return i.paused.Load()
+}
+
+func (i *dockerInstance) Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error) {
+	if i.Paused() {
+		if err := i.Unpause(); err != nil {
+			return utils.StopResult{}, err
+		}
+	}
+
+	res, err := i.watch.Stop(ctx, timeout)
+	if err == nil {
+		i.logger.Debug("stopped container", zap.Int("exit-code", res.ExitCode), zap.Duration("duration", res.Duration))
+	}
+	return res, err
+}
+
+func (i *dockerInstance) Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error) {
+	return utils.DockerExecResult(ctx, i.creator.dockerClient, i.logger, i.containerId, cmd, stdin)
+}
+
+func (i *dockerInstance) Cleanup() {
+	i.creator.runningMutex.Lock()
+	delete(i.creator.running, i)
+	i.creator.runningMutex.Unlock()
+
+	i.watch.Expect()
+	if i.stopGracefully {
+		res, err := i.Stop(context.Background(), dockerStopTimeout)
+		if err != nil {
+			i.logger.Error("failed to stop container", zap.Error(err))
+		} else if res.ExitCode != 0 || res.TimedOut {
+			i.logger.Error("icingadb did not shut down cleanly, coverage data may be missing",
+				zap.Int("exit-code", res.ExitCode), zap.Bool("timed-out", res.TimedOut))
+		}
+	}
+
+	err := i.creator.dockerClient.ContainerRemove(context.Background(), i.containerId, types.ContainerRemoveOptions{
+		Force:         true,
+		RemoveVolumes: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	i.logger.Debug("removed container")
+
+	err = os.Remove(i.configFileName)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -1,56 +1,52 @@
 package icingadb
 
 import (
-	"context"
-	"fmt"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/icinga/icinga-testing/services"
 	"github.com/icinga/icinga-testing/utils"
 	"go.uber.org/zap"
-	"io/ioutil"
-	"os"
 	"path/filepath"
-	"sync"
-	"sync/atomic"
 )
 
 type dockerBinaryCreator struct {
-	logger              *zap.Logger
-	dockerClient        *client.Client
-	dockerNetworkId     string
-	containerNamePrefix string
-	binaryPath          string
-	containerCounter    uint32
-
-	runningMutex sync.Mutex
-	running      map[*dockerBinaryInstance]struct{}
+	dockerCreator
+	binaryPath string
 }
 
 var _ Creator = (*dockerBinaryCreator)(nil)
 
+// NewDockerBinaryCreator returns a creator that runs the Icinga DB binary at binaryPath in a container based on the
+// ICINGA_TESTING_ICINGADB_BASE_IMAGE image (default: "alpine:latest"). Statically linked binaries (CGO_ENABLED=0) work
+// with any image, others like binaries built using -race require an image with a compatible C library. If
+// coverageProfile is not empty, the binary must be built using "go build -cover" and the coverage data of all
+// instances is merged into that file by Cleanup. If faketimeSupported is set, the binary must be built using
+// BuildOptions.Faketime, which allows using services.WithIcingaDbClock.
 func NewDockerBinaryCreator(
 	logger *zap.Logger,
 	dockerClient *client.Client,
 	containerNamePrefix string,
 	dockerNetworkId string,
 	binaryPath string,
+	coverageProfile string,
+	faketimeSupported bool,
 ) Creator {
 	binaryPath, err := filepath.Abs(binaryPath)
 	if err != nil {
 		panic(err)
 	}
-	return &dockerBinaryCreator{
-		logger:              logger.With(zap.Bool("icingadb", true)),
-		dockerClient:        dockerClient,
-		dockerNetworkId:     dockerNetworkId,
-		containerNamePrefix: containerNamePrefix,
-		binaryPath:          binaryPath,
-		running:             make(map[*dockerBinaryInstance]struct{}),
+	c := &dockerBinaryCreator{
+		dockerCreator: newDockerCreator(logger, dockerClient, containerNamePrefix, dockerNetworkId),
+		binaryPath:    binaryPath,
+	}
+	c.faketimeSupported = faketimeSupported
+	if coverageProfile != "" {
+		c.coverage, err = newCoverage(coverageProfile)
+		if err != nil {
+			panic(err)
+		}
 	}
+	return c
 }
 
 func (i *dockerBinaryCreator) CreateIcingaDb(
@@ -58,136 +54,16 @@ func (i *dockerBinaryCreator) CreateIcingaDb(
 	rdb services.RelationalDatabase,
 	options ...services.IcingaDbOption,
 ) services.IcingaDbBase {
-	inst := &dockerBinaryInstance{
-		info: info{
-			redis: redis,
-			rdb:   rdb,
-		},
-		logger:               i.logger,
-		icingaDbDockerBinary: i,
-	}
-
-	configFile, err := ioutil.TempFile("", "icingadb.yml")
-	if err != nil {
-		panic(err)
-	}
-	idb := &services.IcingaDb{IcingaDbBase: inst}
-	for _, option := range options {
-		option(idb)
-	}
-	if err = idb.WriteConfig(configFile); err != nil {
-		panic(err)
-	}
-	inst.configFileName = configFile.Name()
-	err = configFile.Close()
-	if err != nil {
-		panic(err)
-	}
-
-	containerName := fmt.Sprintf("%s-%d", i.containerNamePrefix, atomic.AddUint32(&i.containerCounter, 1))
-	inst.logger = inst.logger.With(zap.String("container-name", containerName))
-	networkName, err := utils.DockerNetworkName(context.Background(), i.dockerClient, i.dockerNetworkId)
-	if err != nil {
-		panic(err)
-	}
-
-	dockerImage := "alpine:latest"
-	err = utils.DockerImagePull(context.Background(), inst.logger, i.dockerClient, dockerImage, false)
-	if err != nil {
-		panic(err)
-	}
-
-	cont, err := i.dockerClient.ContainerCreate(context.Background(), &container.Config{
-		Image: dockerImage,
-		Cmd:   []string{"/icingadb", "--config", "/icingadb.yml"},
-	}, &container.HostConfig{
-		Mounts: []mount.Mount{{
-			Type:     mount.TypeBind,
-			Source:   i.binaryPath,
-			Target:   "/icingadb",
-			ReadOnly: true,
-		}, {
-			Type:     mount.TypeBind,
-			Source:   inst.configFileName,
-			Target:   "/icingadb.yml",
-			ReadOnly: true,
-		}},
-	}, &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			networkName: {
-				NetworkID: i.dockerNetworkId,
-			},
-		},
-	}, nil, containerName)
-	if err != nil {
-		inst.logger.Fatal("failed to create icingadb container", zap.Error(err))
-	}
-	inst.containerId = cont.ID
-	inst.logger = inst.logger.With(zap.String("container-id", cont.ID))
-	inst.logger.Debug("created container")
-
-	err = utils.ForwardDockerContainerOutput(context.Background(), i.dockerClient, cont.ID,
-		false, utils.NewLineWriter(func(line []byte) {
-			inst.logger.Debug("container output",
-				zap.ByteString("line", line))
-		}))
-	if err != nil {
-		inst.logger.Fatal("failed to attach to container output",
-			zap.Error(err))
-	}
-
-	err = i.dockerClient.ContainerStart(context.Background(), cont.ID, types.ContainerStartOptions{})
-	if err != nil {
-		inst.logger.Fatal("failed to start container", zap.Error(err))
-	}
-	inst.logger.Debug("started container")
-
-	i.runningMutex.Lock()
-	i.running[inst] = struct{}{}
-	i.runningMutex.Unlock()
-
-	return inst
-}
-
-func (i *dockerBinaryCreator) Cleanup() {
-	i.runningMutex.Lock()
-	instances := make([]*dockerBinaryInstance, 0, len(i.running))
-	for inst := range i.running {
-		instances = append(instances, inst)
-	}
-	i.runningMutex.Unlock()
-
-	for _, inst := range instances {
-		inst.Cleanup()
-	}
-}
-
-type dockerBinaryInstance struct {
-	info
-	icingaDbDockerBinary *dockerBinaryCreator
-	logger               *zap.Logger
-	containerId          string
-	configFileName       string
-}
-
-var _ services.IcingaDbBase = (*dockerBinaryInstance)(nil)
-
-func (i *dockerBinaryInstance) Cleanup() {
-	i.icingaDbDockerBinary.runningMutex.Lock()
-	delete(i.icingaDbDockerBinary.running, i)
-	i.icingaDbDockerBinary.runningMutex.Unlock()
-
-	err := i.icingaDbDockerBinary.dockerClient.ContainerRemove(context.Background(), i.containerId, types.ContainerRemoveOptions{
-		Force:         true,
-		RemoveVolumes: true,
-	})
-	if err != nil {
-		panic(err)
-	}
-	i.logger.Debug("removed container")
-
-	err = os.Remove(i.configFileName)
-	if err != nil {
-		panic(err)
-	}
+	return i.createInstance(redis, rdb, func(*services.IcingaDb) dockerContainerSpec {
+		return dockerContainerSpec{
+			image:      utils.GetEnvDefault("ICINGA_TESTING_ICINGADB_BASE_IMAGE", "alpine:latest"),
+			entrypoint: []string{"/icingadb"},
+			mounts: []mount.Mount{{
+				Type:     mount.TypeBind,
+				Source:   i.binaryPath,
+				Target:   "/icingadb",
+				ReadOnly: true,
+			}},
+		}
+	}, options...)
 }
@@ -0,0 +1,47 @@
+package icingadb
+
+import (
+	"github.com/docker/docker/client"
+	"github.com/icinga/icinga-testing/services"
+	"github.com/icinga/icinga-testing/utils"
+	"go.uber.org/zap"
+)
+
+// dockerImageBinaryPath is the location of the Icinga DB binary within the icinga/icingadb container images.
+const dockerImageBinaryPath = "/icingadb"
+
+type dockerImageCreator struct {
+	dockerCreator
+}
+
+var _ Creator = (*dockerImageCreator)(nil)
+
+// NewDockerImageCreator returns a creator that runs Icinga DB from a container image like icinga/icingadb, bypassing
+// the entrypoint of the image so that the same rendered config is used as for binaries. The image is taken from
+// services.WithIcingaDbImage if given, otherwise from the ICINGA_TESTING_ICINGADB_IMAGE environment variable
+// (default: "icinga/icingadb:latest").
+func NewDockerImageCreator(
+	logger *zap.Logger, dockerClient *client.Client, containerNamePrefix string, dockerNetworkId string,
+) Creator {
+	return &dockerImageCreator{
+		dockerCreator: newDockerCreator(logger, dockerClient, containerNamePrefix, dockerNetworkId),
+	}
+}
+
+func (i *dockerImageCreator) CreateIcingaDb(
+	redis services.RedisServerBase,
+	rdb services.RelationalDatabase,
+	options ...services.IcingaDbOption,
+) services.IcingaDbBase {
+	return i.createInstance(redis, rdb, func(idb *services.IcingaDb) dockerContainerSpec {
+		image := idb.Image()
+		if image == "" {
+			image = utils.GetEnvDefault("ICINGA_TESTING_ICINGADB_IMAGE", "icinga/icingadb:latest")
+		}
+
+		return dockerContainerSpec{
+			image:      image,
+			entrypoint: []string{dockerImageBinaryPath},
+		}
+	}, options...)
+}
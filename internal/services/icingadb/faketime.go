@@ -0,0 +1,53 @@
+package icingadb
+
+import (
+	"bytes"
+	_ "embed"
+	"os"
+	"path/filepath"
+)
+
+// faketimeTimeSource is the file added to the time package for BuildOptions.Faketime. Its build constraint keeps it
+// out of this package and is removed by faketimeOverlayDir.
+//
+//go:embed faketime_time.go
+var faketimeTimeSource []byte
+
+// faketimeBuildConstraint is the first line of faketimeTimeSource.
+const faketimeBuildConstraint = "//go:build icinga_testing_faketime\n"
+
+// faketimeOverlayScript runs go build with the arguments following the overlay directory given as first argument. It
+// writes a copy of time.go of the Go installation with Now renamed to realNow to the overlay directory and passes both
+// this copy and faketime.go written by faketimeOverlayDir to go build using -overlay. This is done within the builder,
+// as only it knows its Go installation.
+const faketimeOverlayScript = `set -e
+overlay=$1
+shift
+goroot=$(go env GOROOT)
+sed 's/^func Now() Time {$/func realNow() Time {/' "$goroot/src/time/time.go" >"$overlay/time.go"
+if ! grep -q '^func realNow() Time {$' "$overlay/time.go"; then
+	echo "func Now() not found in $goroot/src/time/time.go" >&2
+	exit 1
+fi
+printf '{"Replace": {"%s": "%s", "%s": "%s"}}\n' \
+	"$goroot/src/time/time.go" "$overlay/time.go" \
+	"$goroot/src/time/icinga_testing_faketime.go" "$overlay/faketime.go" >"$overlay/overlay.json"
+exec go build -overlay "$overlay/overlay.json" "$@"
+`
+
+// faketimeOverlayDir creates a temporary directory for faketimeOverlayScript containing faketime.go. The caller has
+// to remove it after the build.
+func faketimeOverlayDir() (string, error) {
+	dir, err := os.MkdirTemp("", "icinga-testing-faketime-overlay")
+	if err != nil {
+		return "", err
+	}
+
+	source := bytes.TrimPrefix(faketimeTimeSource, []byte(faketimeBuildConstraint))
+	if err := os.WriteFile(filepath.Join(dir, "faketime.go"), source, 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,96 @@
+package icingadb
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaketimeOverlay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build of the time package in short mode")
+	}
+	for _, name := range []string{"go", "sh"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not available: %v", name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	write := func(name string, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module faketimetest\n\ngo 1.20\n")
+	write("main.go", `package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	start := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	fmt.Println(start.Unix(), time.Since(start) > 0)
+}
+`)
+	write("faketimerc", "+3600.000000\n")
+
+	overlayDir, err := faketimeOverlayDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(overlayDir) }()
+
+	binary := filepath.Join(dir, "faketimetest")
+	args := buildCommand(overlayDir, []string{"-o", binary, "."})
+	build := exec.CommandContext(context.Background(), args[0], args[1:]...)
+	build.Dir = dir
+	build.Env = append(os.Environ(), "CGO_ENABLED=0", "GOTOOLCHAIN=local")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building with faketime overlay failed: %v\n%s", err, out)
+	}
+
+	run := func(env ...string) (time.Time, bool) {
+		t.Helper()
+		cmd := exec.Command(binary)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("running %s failed: %v", binary, err)
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) != 2 {
+			t.Fatalf("unexpected output %q", out)
+		}
+		unix, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Unix(unix, 0), fields[1] == "true"
+	}
+
+	now, elapsed := run()
+	if d := time.Since(now); d < -time.Minute || d > time.Minute {
+		t.Errorf("time without FAKETIME_TIMESTAMP_FILE is off by %v", d)
+	}
+	if !elapsed {
+		t.Errorf("time.Since without FAKETIME_TIMESTAMP_FILE is not positive")
+	}
+
+	now, elapsed = run("FAKETIME_TIMESTAMP_FILE=" + filepath.Join(dir, "faketimerc"))
+	if d := time.Until(now) - time.Hour; d < -time.Minute || d > time.Minute {
+		t.Errorf("time with FAKETIME_TIMESTAMP_FILE is not shifted by an hour, off by %v", d)
+	}
+	if !elapsed {
+		t.Errorf("time.Since with FAKETIME_TIMESTAMP_FILE is not positive")
+	}
+}
@@ -0,0 +1,110 @@
+//go:build icinga_testing_faketime
+
+// This file is not part of this package, but added to the time package of the Go standard library when building Icinga
+// DB with faketime support, see BuildOptions.Faketime. The Now function of the time package is renamed to realNow then,
+// so that the Now function defined here can add the offset of a faketime.Clock to the real time.
+
+package time
+
+import (
+	"sync/atomic"
+	"syscall"
+)
+
+// faketimeCacheDuration is how long the offset read from the timestamp file is used before reading it again.
+const faketimeCacheDuration = 10 * Millisecond
+
+// faketimeState is the offset read from the timestamp file of a faketime.Clock. It is valid until runtimeNano reaches
+// expires.
+type faketimeState struct {
+	expires int64
+	offset  Duration
+	speed   float64
+}
+
+var (
+	// faketimeFile is the path of the timestamp file, like for libfaketime taken from FAKETIME_TIMESTAMP_FILE.
+	faketimeFile, faketimeEnabled = syscall.Getenv("FAKETIME_TIMESTAMP_FILE")
+
+	// faketimeStart is the real time at which the process started. Like libfaketime, the speed of the clock is
+	// applied relative to it.
+	faketimeStart = realNow()
+
+	faketimeCurrent atomic.Pointer[faketimeState]
+)
+
+// Now returns the current time as controlled by the timestamp file. Like the times passed to Since and Until, it has
+// no monotonic clock reading if the clock is shifted, so that durations are calculated from the shifted times.
+func Now() Time {
+	now := realNow()
+	if !faketimeEnabled {
+		return now
+	}
+
+	state := faketimeCurrent.Load()
+	if state == nil || runtimeNano() >= state.expires {
+		state = faketimeRead()
+		faketimeCurrent.Store(state)
+	}
+	if state.offset == 0 && state.speed == 1 {
+		return now
+	}
+
+	elapsed := now.Sub(faketimeStart)
+	return faketimeStart.Add(Duration(float64(elapsed)*state.speed) + state.offset).Round(0)
+}
+
+// faketimeRead reads and parses the timestamp file, which contains the offset in seconds, optionally followed by the
+// speed, for example "+5400.000000 x2". If the file cannot be read, the real time is used.
+func faketimeRead() *faketimeState {
+	state := &faketimeState{expires: runtimeNano() + int64(faketimeCacheDuration), speed: 1}
+
+	fd, err := syscall.Open(faketimeFile, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return state
+	}
+	var buf [64]byte
+	n, err := syscall.Read(fd, buf[:])
+	_ = syscall.Close(fd)
+	if err != nil || n <= 0 {
+		return state
+	}
+
+	spec := string(buf[:n])
+	offset, spec := faketimeParseFloat(spec)
+	state.offset = Duration(offset * float64(Second))
+	if len(spec) > 2 && spec[0] == ' ' && spec[1] == 'x' {
+		if speed, _ := faketimeParseFloat(spec[2:]); speed > 0 {
+			state.speed = speed
+		}
+	}
+
+	return state
+}
+
+// faketimeParseFloat parses a decimal number with an optional sign and fraction at the start of s and returns it
+// together with the rest of s. The strconv package cannot be used within the time package.
+func faketimeParseFloat(s string) (float64, string) {
+	sign := 1.0
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+
+	var value float64
+	for len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		value = value*10 + float64(s[0]-'0')
+		s = s[1:]
+	}
+	if len(s) > 0 && s[0] == '.' {
+		s = s[1:]
+		for scale := 0.1; len(s) > 0 && s[0] >= '0' && s[0] <= '9'; scale /= 10 {
+			value += float64(s[0]-'0') * scale
+			s = s[1:]
+		}
+	}
+
+	return sign * value, s
+}
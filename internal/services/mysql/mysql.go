@@ -1,12 +1,19 @@
 package mysql
 
 import (
+	"context"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/icinga/icinga-testing/services"
+	"github.com/icinga/icinga-testing/utils"
+	"io"
+	"time"
 )
 
 type Creator interface {
 	CreateMysqlDatabase() services.MysqlDatabaseBase
+	// CreateIcingaDbDatabase is like CreateMysqlDatabase, but the database already contains the Icinga DB schema.
+	CreateIcingaDbDatabase() services.MysqlDatabaseBase
 	Cleanup()
 }
 
@@ -44,6 +51,22 @@ type mysqlDatabaseNopCleanup struct {
 	info
 }
 
+func (_ *mysqlDatabaseNopCleanup) Exec(context.Context, []string, io.Reader) (utils.ExecResult, error) {
+	return utils.ExecResult{}, errors.New("exec is not supported on this connection")
+}
+
+func (_ *mysqlDatabaseNopCleanup) ExitStatus() (utils.ExitStatus, bool) {
+	return utils.ExitStatus{}, false
+}
+
+func (_ *mysqlDatabaseNopCleanup) Exited() <-chan struct{} {
+	return nil
+}
+
+func (_ *mysqlDatabaseNopCleanup) Stop(context.Context, time.Duration) (utils.StopResult, error) {
+	return utils.StopResult{}, errors.New("stop is not supported on this connection")
+}
+
 func (_ *mysqlDatabaseNopCleanup) Cleanup() {}
 
 var _ services.MysqlDatabaseBase = (*mysqlDatabaseNopCleanup)(nil)
@@ -0,0 +1,141 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/icinga/icinga-testing/services"
+)
+
+// templateDatabase is the name of the database the Icinga DB schema is imported into once and cloned from.
+const templateDatabase = "icingadb_template"
+
+// CreateIcingaDbDatabase creates a new database with the Icinga DB schema and a user to access it. The tables and
+// their rows are cloned from a template database into which the schema is imported only once, on the first call.
+func (m *rootConnection) CreateIcingaDbDatabase() services.MysqlDatabaseBase {
+	m.ensureTemplate()
+
+	d := m.CreateMysqlDatabase()
+	if err := m.cloneTemplate(d.Database()); err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// ensureTemplate prepares the template database unless this was already done successfully.
+func (m *rootConnection) ensureTemplate() {
+	m.templateMutex.Lock()
+	defer m.templateMutex.Unlock()
+
+	if !m.templateReady {
+		if err := m.prepareTemplate(); err != nil {
+			panic(err)
+		}
+		m.templateReady = true
+	}
+}
+
+// prepareTemplate creates the template database and imports the Icinga DB schema into it. Any leftovers of a previous
+// attempt that failed are removed first.
+func (m *rootConnection) prepareTemplate() error {
+	_, err := m.db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", templateDatabase))
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(fmt.Sprintf("CREATE DATABASE %s", templateDatabase))
+	if err != nil {
+		return err
+	}
+
+	template := services.MysqlDatabase{MysqlDatabaseBase: &mysqlDatabaseNopCleanup{info{
+		host:     m.host,
+		port:     m.port,
+		username: m.rootUsername,
+		password: m.rootPassword,
+		database: templateDatabase,
+	}}}
+	template.ImportIcingaDbSchema()
+
+	return nil
+}
+
+// cloneTemplate creates all tables of the template database in the given database and copies their rows.
+//
+// The tables are created from the output of SHOW CREATE TABLE as, unlike CREATE TABLE ... LIKE, it includes foreign
+// keys. All statements use the same connection so that the foreign key checks stay disabled while the tables are
+// created in arbitrary order. As this changes the state of its session, the connection is discarded afterwards instead
+// of being returned to the pool.
+func (m *rootConnection) cloneTemplate(database string) error {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Returning driver.ErrBadConn makes database/sql close the underlying connection instead of reusing it.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = conn.Close()
+	}()
+
+	var tables []string
+	rows, err := conn.QueryContext(ctx, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = ? AND table_type = 'BASE TABLE'`, templateDatabase)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET SESSION foreign_key_checks = 0"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("USE %s", database)); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		var name, create string
+		err := conn.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE %s.%s", templateDatabase, table)).
+			Scan(&name, &create)
+		if err != nil {
+			return err
+		}
+
+		if _, err := conn.ExecContext(ctx, create); err != nil {
+			return fmt.Errorf("failed to clone table %q: %w", table, err)
+		}
+	}
+
+	// Most tables are empty, but some contain rows inserted by the schema, for example the schema version.
+	for _, table := range tables {
+		var hasRows bool
+		err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s.%s)", templateDatabase, table)).
+			Scan(&hasRows)
+		if err != nil {
+			return err
+		}
+		if !hasRows {
+			continue
+		}
+
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s.%s SELECT * FROM %s.%s",
+			database, table, templateDatabase, table))
+		if err != nil {
+			return fmt.Errorf("failed to copy rows of table %q: %w", table, err)
+		}
+	}
+
+	return nil
+}
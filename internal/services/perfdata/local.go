@@ -0,0 +1,456 @@
+package perfdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/icinga/icinga-testing/services"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// localCreator starts receivers for the different perfdata writer protocols within the current process. They listen on
+// the given address which must be reachable from the containers, usually the gateway address of the docker network.
+type localCreator struct {
+	logger     *zap.Logger
+	listenHost string
+
+	runningMutex sync.Mutex
+	running      map[io.Closer]struct{}
+}
+
+var _ Creator = (*localCreator)(nil)
+
+func NewLocalCreator(logger *zap.Logger, listenHost string) Creator {
+	return &localCreator{
+		logger:     logger.With(zap.Bool("perfdata", true)),
+		listenHost: listenHost,
+		running:    make(map[io.Closer]struct{}),
+	}
+}
+
+// listen creates a new TCP listener on a random port and initializes the receiver base from it.
+func (c *localCreator) listen(protocol string) *receiver {
+	l, err := net.Listen("tcp", net.JoinHostPort(c.listenHost, "0"))
+	if err != nil {
+		panic(err)
+	}
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		panic(err)
+	}
+
+	r := &receiver{
+		info:     info{host: host, port: port},
+		creator:  c,
+		logger:   c.logger.With(zap.String("protocol", protocol), zap.String("address", l.Addr().String())),
+		listener: l,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	r.logger.Debug("started receiver")
+
+	return r
+}
+
+func (c *localCreator) register(r io.Closer) {
+	c.runningMutex.Lock()
+	c.running[r] = struct{}{}
+	c.runningMutex.Unlock()
+}
+
+func (c *localCreator) CreateGraphiteReceiver() services.GraphiteReceiverBase {
+	g := &graphiteReceiver{}
+	g.receiver = c.listen("graphite")
+	g.serveStream(bufio.ScanLines, func(msg []byte) error {
+		m, err := parseGraphiteLine(string(msg))
+		if err != nil {
+			return err
+		}
+		g.mutex.Lock()
+		g.metrics = append(g.metrics, m)
+		g.mutex.Unlock()
+		return nil
+	})
+	c.register(g)
+
+	return g
+}
+
+func (c *localCreator) CreateInfluxdbReceiver() services.InfluxdbReceiverBase {
+	i := &influxdbReceiver{}
+	i.receiver = c.listen("influxdb")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	write := func(database string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			q := req.URL.Query()
+			db := q.Get(database)
+			var points []services.InfluxdbPoint
+
+			scanner := bufio.NewScanner(req.Body)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				p, err := parseInfluxdbLine(line, q.Get("precision"))
+				if err != nil {
+					i.logger.Error("failed to parse influxdb line", zap.String("line", line), zap.Error(err))
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				p.Database = db
+				points = append(points, p)
+			}
+			if err := scanner.Err(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			i.logger.Debug("received influxdb points", zap.String("database", db), zap.Int("count", len(points)))
+			i.mutex.Lock()
+			i.points = append(i.points, points...)
+			i.mutex.Unlock()
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.HandleFunc("/write", write("db"))
+	mux.HandleFunc("/api/v2/write", write("bucket"))
+
+	i.serveHttp(mux)
+	c.register(i)
+
+	return i
+}
+
+func (c *localCreator) CreateOpenTsdbReceiver() services.OpenTsdbReceiverBase {
+	o := &openTsdbReceiver{}
+	o.receiver = c.listen("opentsdb")
+	o.serveStream(bufio.ScanLines, func(msg []byte) error {
+		m, err := parseOpenTsdbLine(string(msg))
+		if err != nil {
+			return err
+		}
+		o.mutex.Lock()
+		o.metrics = append(o.metrics, m)
+		o.mutex.Unlock()
+		return nil
+	})
+	c.register(o)
+
+	return o
+}
+
+func (c *localCreator) CreateGelfReceiver() services.GelfReceiverBase {
+	g := &gelfReceiver{}
+	g.receiver = c.listen("gelf")
+	g.serveStream(scanNullTerminated, func(msg []byte) error {
+		var m services.GelfMessage
+		if err := json.Unmarshal(msg, &m); err != nil {
+			return err
+		}
+		g.mutex.Lock()
+		g.messages = append(g.messages, m)
+		g.mutex.Unlock()
+		return nil
+	})
+	c.register(g)
+
+	return g
+}
+
+func (c *localCreator) CreateElasticsearchReceiver() services.ElasticsearchReceiverBase {
+	e := &elasticsearchReceiver{}
+	e.receiver = c.listen("elasticsearch")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		index, bulk := parseElasticsearchBulkPath(req.URL.Path)
+		if req.Method != http.MethodPost || !bulk {
+			// Pretend to be some recent Elasticsearch version for any other request.
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"version":{"number":"7.17.0"},"tagline":"You Know, for Search"}`))
+			return
+		}
+
+		var docs []services.ElasticsearchDocument
+		dec := json.NewDecoder(req.Body)
+		for {
+			// The bulk API body consists of pairs of lines, an action and the document itself.
+			var action map[string]struct {
+				Index string `json:"_index"`
+			}
+			if err := dec.Decode(&action); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				e.logger.Error("failed to decode elasticsearch bulk action", zap.Error(err))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var source map[string]interface{}
+			if err := dec.Decode(&source); err != nil {
+				e.logger.Error("failed to decode elasticsearch document", zap.Error(err))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			doc := services.ElasticsearchDocument{Index: index, Source: source}
+			for _, a := range action {
+				if a.Index != "" {
+					doc.Index = a.Index
+				}
+			}
+			docs = append(docs, doc)
+		}
+
+		e.logger.Debug("received elasticsearch documents", zap.String("index", index), zap.Int("count", len(docs)))
+		e.mutex.Lock()
+		e.documents = append(e.documents, docs...)
+		e.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"took":0,"errors":false,"items":[]}`))
+	})
+
+	e.serveHttp(mux)
+	c.register(e)
+
+	return e
+}
+
+func (c *localCreator) Cleanup() {
+	c.runningMutex.Lock()
+	receivers := make([]io.Closer, 0, len(c.running))
+	for r := range c.running {
+		receivers = append(receivers, r)
+	}
+	c.runningMutex.Unlock()
+
+	for _, r := range receivers {
+		_ = r.Close()
+	}
+}
+
+// receiver contains the functionality shared by all receivers: accepting connections and stopping the receiver.
+type receiver struct {
+	info
+	creator  *localCreator
+	logger   *zap.Logger
+	listener net.Listener
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	conns    map[net.Conn]struct{}
+	server   *http.Server
+}
+
+// serveStream accepts connections and calls handle for every message read from them, as determined by split.
+func (r *receiver) serveStream(split bufio.SplitFunc, handle func(msg []byte) error) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		for {
+			conn, err := r.listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					r.logger.Error("failed to accept connection", zap.Error(err))
+				}
+				return
+			}
+
+			r.mutex.Lock()
+			r.conns[conn] = struct{}{}
+			r.mutex.Unlock()
+
+			r.wg.Add(1)
+			go func() {
+				defer r.wg.Done()
+				defer func() {
+					r.mutex.Lock()
+					delete(r.conns, conn)
+					r.mutex.Unlock()
+					_ = conn.Close()
+				}()
+
+				scanner := bufio.NewScanner(conn)
+				scanner.Buffer(nil, 16*1024*1024)
+				scanner.Split(split)
+				for scanner.Scan() {
+					msg := bytes.TrimSpace(scanner.Bytes())
+					if len(msg) == 0 {
+						continue
+					}
+					r.logger.Debug("received message", zap.ByteString("message", msg))
+					if err := handle(msg); err != nil {
+						r.logger.Error("failed to handle message", zap.ByteString("message", msg), zap.Error(err))
+					}
+				}
+			}()
+		}
+	}()
+}
+
+// serveHttp serves HTTP requests using the given handler.
+func (r *receiver) serveHttp(handler http.Handler) {
+	r.server = &http.Server{Handler: handler}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.server.Serve(r.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Error("http server failed", zap.Error(err))
+		}
+	}()
+}
+
+// stop closes the listener and all connections and waits for all goroutines to finish.
+func (r *receiver) stop(self io.Closer) {
+	r.creator.runningMutex.Lock()
+	delete(r.creator.running, self)
+	r.creator.runningMutex.Unlock()
+
+	if r.server != nil {
+		_ = r.server.Close()
+	} else {
+		_ = r.listener.Close()
+		r.mutex.Lock()
+		for conn := range r.conns {
+			_ = conn.Close()
+		}
+		r.mutex.Unlock()
+	}
+
+	r.wg.Wait()
+	r.logger.Debug("stopped receiver")
+}
+
+// scanNullTerminated is a bufio.SplitFunc that splits the input at null bytes as used by GELF over TCP.
+func scanNullTerminated(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+type graphiteReceiver struct {
+	*receiver
+	metrics []services.GraphiteMetric
+}
+
+var _ services.GraphiteReceiverBase = (*graphiteReceiver)(nil)
+
+func (g *graphiteReceiver) Metrics() []services.GraphiteMetric {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]services.GraphiteMetric(nil), g.metrics...)
+}
+
+func (g *graphiteReceiver) Close() error {
+	g.stop(g)
+	return nil
+}
+
+func (g *graphiteReceiver) Cleanup() {
+	_ = g.Close()
+}
+
+type influxdbReceiver struct {
+	*receiver
+	points []services.InfluxdbPoint
+}
+
+var _ services.InfluxdbReceiverBase = (*influxdbReceiver)(nil)
+
+func (i *influxdbReceiver) Points() []services.InfluxdbPoint {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]services.InfluxdbPoint(nil), i.points...)
+}
+
+func (i *influxdbReceiver) Close() error {
+	i.stop(i)
+	return nil
+}
+
+func (i *influxdbReceiver) Cleanup() {
+	_ = i.Close()
+}
+
+type openTsdbReceiver struct {
+	*receiver
+	metrics []services.OpenTsdbMetric
+}
+
+var _ services.OpenTsdbReceiverBase = (*openTsdbReceiver)(nil)
+
+func (o *openTsdbReceiver) Metrics() []services.OpenTsdbMetric {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]services.OpenTsdbMetric(nil), o.metrics...)
+}
+
+func (o *openTsdbReceiver) Close() error {
+	o.stop(o)
+	return nil
+}
+
+func (o *openTsdbReceiver) Cleanup() {
+	_ = o.Close()
+}
+
+type gelfReceiver struct {
+	*receiver
+	messages []services.GelfMessage
+}
+
+var _ services.GelfReceiverBase = (*gelfReceiver)(nil)
+
+func (g *gelfReceiver) Messages() []services.GelfMessage {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]services.GelfMessage(nil), g.messages...)
+}
+
+func (g *gelfReceiver) Close() error {
+	g.stop(g)
+	return nil
+}
+
+func (g *gelfReceiver) Cleanup() {
+	_ = g.Close()
+}
+
+type elasticsearchReceiver struct {
+	*receiver
+	documents []services.ElasticsearchDocument
+}
+
+var _ services.ElasticsearchReceiverBase = (*elasticsearchReceiver)(nil)
+
+func (e *elasticsearchReceiver) Documents() []services.ElasticsearchDocument {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]services.ElasticsearchDocument(nil), e.documents...)
+}
+
+func (e *elasticsearchReceiver) Close() error {
+	e.stop(e)
+	return nil
+}
+
+func (e *elasticsearchReceiver) Cleanup() {
+	_ = e.Close()
+}
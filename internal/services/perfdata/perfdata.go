@@ -0,0 +1,28 @@
+package perfdata
+
+import (
+	"github.com/icinga/icinga-testing/services"
+)
+
+type Creator interface {
+	CreateGraphiteReceiver() services.GraphiteReceiverBase
+	CreateInfluxdbReceiver() services.InfluxdbReceiverBase
+	CreateOpenTsdbReceiver() services.OpenTsdbReceiverBase
+	CreateGelfReceiver() services.GelfReceiverBase
+	CreateElasticsearchReceiver() services.ElasticsearchReceiverBase
+	Cleanup()
+}
+
+// info provides a partial implementation of the receiver interfaces in the services package.
+type info struct {
+	host string
+	port string
+}
+
+func (i *info) Host() string {
+	return i.host
+}
+
+func (i *info) Port() string {
+	return i.port
+}
@@ -0,0 +1,219 @@
+package perfdata
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/icinga/icinga-testing/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseGraphiteLine parses a single line of the Graphite plaintext protocol: "<path> <value> <timestamp>".
+func parseGraphiteLine(line string) (services.GraphiteMetric, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 3 {
+		return services.GraphiteMetric{}, fmt.Errorf("expected 3 fields in graphite line, got %d", len(parts))
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return services.GraphiteMetric{}, fmt.Errorf("invalid graphite value %q: %w", parts[1], err)
+	}
+
+	ts, err := parseUnixTimestamp(parts[2])
+	if err != nil {
+		return services.GraphiteMetric{}, err
+	}
+
+	return services.GraphiteMetric{Path: parts[0], Value: value, Timestamp: ts}, nil
+}
+
+// parseOpenTsdbLine parses a single line of the OpenTSDB telnet protocol:
+// "put <metric> <timestamp> <value> <tagk1=tagv1 ...>".
+func parseOpenTsdbLine(line string) (services.OpenTsdbMetric, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 4 || parts[0] != "put" {
+		return services.OpenTsdbMetric{}, fmt.Errorf("expected put command with at least 3 arguments, got %q", line)
+	}
+
+	ts, err := parseUnixTimestamp(parts[2])
+	if err != nil {
+		return services.OpenTsdbMetric{}, err
+	}
+
+	value, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil {
+		return services.OpenTsdbMetric{}, fmt.Errorf("invalid opentsdb value %q: %w", parts[3], err)
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range parts[4:] {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok {
+			return services.OpenTsdbMetric{}, fmt.Errorf("invalid opentsdb tag %q", tag)
+		}
+		tags[k] = v
+	}
+
+	return services.OpenTsdbMetric{Metric: parts[1], Value: value, Timestamp: ts, Tags: tags}, nil
+}
+
+// parseElasticsearchBulkPath returns whether path is a request to the Elasticsearch bulk API, i.e. "/_bulk",
+// "/<index>/_bulk" or "/<index>/<type>/_bulk", and the default index given in the path, if any.
+func parseElasticsearchBulkPath(path string) (index string, bulk bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 3 || parts[len(parts)-1] != "_bulk" {
+		return "", false
+	}
+	if len(parts) > 1 {
+		index = parts[0]
+	}
+	return index, true
+}
+
+// parseUnixTimestamp parses a timestamp given in seconds since the epoch, optionally with a fractional part.
+func parseUnixTimestamp(s string) (time.Time, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", s, err)
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), nil
+}
+
+// parseInfluxdbLine parses a single line of the InfluxDB line protocol:
+// "<measurement>[,<tag_key>=<tag_value>...] <field_key>=<field_value>[,...] [<timestamp>]".
+//
+// The precision is the value of the precision query parameter of the write request ("ns", "us", "ms" or "s", the
+// InfluxDB 1.x API also uses "n" and "u").
+func parseInfluxdbLine(line string, precision string) (services.InfluxdbPoint, error) {
+	parts := splitUnescaped(line, ' ', true)
+	if len(parts) != 2 && len(parts) != 3 {
+		return services.InfluxdbPoint{}, fmt.Errorf("expected 2 or 3 space-separated sections, got %d", len(parts))
+	}
+
+	key := splitUnescaped(parts[0], ',', false)
+	point := services.InfluxdbPoint{
+		Measurement: unescape(key[0]),
+		Tags:        make(map[string]string),
+		Fields:      make(map[string]interface{}),
+	}
+
+	for _, tag := range key[1:] {
+		k, v, ok := cutUnescaped(tag, '=')
+		if !ok {
+			return services.InfluxdbPoint{}, fmt.Errorf("invalid tag %q", tag)
+		}
+		point.Tags[unescape(k)] = unescape(v)
+	}
+
+	for _, field := range splitUnescaped(parts[1], ',', true) {
+		k, v, ok := cutUnescaped(field, '=')
+		if !ok {
+			return services.InfluxdbPoint{}, fmt.Errorf("invalid field %q", field)
+		}
+		value, err := parseInfluxdbFieldValue(v)
+		if err != nil {
+			return services.InfluxdbPoint{}, err
+		}
+		point.Fields[unescape(k)] = value
+	}
+
+	if len(parts) == 3 {
+		ts, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return services.InfluxdbPoint{}, fmt.Errorf("invalid timestamp %q: %w", parts[2], err)
+		}
+
+		switch precision {
+		case "", "n", "ns":
+			point.Timestamp = time.Unix(0, ts)
+		case "u", "us":
+			point.Timestamp = time.UnixMicro(ts)
+		case "ms":
+			point.Timestamp = time.UnixMilli(ts)
+		case "s":
+			point.Timestamp = time.Unix(ts, 0)
+		default:
+			return services.InfluxdbPoint{}, fmt.Errorf("unknown precision %q", precision)
+		}
+	}
+
+	return point, nil
+}
+
+// parseInfluxdbFieldValue parses a field value of the InfluxDB line protocol into float64, int64, string or bool.
+func parseInfluxdbFieldValue(v string) (interface{}, error) {
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		return unescape(v[1 : len(v)-1]), nil
+	case strings.HasSuffix(v, "i") || strings.HasSuffix(v, "u"):
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	}
+
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field value %q: %w", v, err)
+	}
+	return f, nil
+}
+
+// splitUnescaped splits s at every occurrence of sep that is not escaped using a backslash. If quotes is true,
+// occurrences within double-quoted strings are ignored as well.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	quoted := false
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// cutUnescaped slices s around the first occurrence of sep that is not escaped using a backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+
+	return s, "", false
+}
+
+// unescape removes the backslash from all backslash-escaped characters in s.
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		buf.WriteByte(s[i])
+	}
+
+	return buf.String()
+}
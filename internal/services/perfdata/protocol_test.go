@@ -0,0 +1,96 @@
+package perfdata
+
+import (
+	"github.com/icinga/icinga-testing/services"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInfluxdbLine(t *testing.T) {
+	line := `ping,hostname=my\ host,service=ping\,4 value=0.5,crit=100i,unit="m\"s",ok=t 1700000000`
+
+	got, err := parseInfluxdbLine(line, "s")
+	if err != nil {
+		t.Fatalf("parseInfluxdbLine() error = %v", err)
+	}
+
+	want := services.InfluxdbPoint{
+		Measurement: "ping",
+		Tags:        map[string]string{"hostname": "my host", "service": "ping,4"},
+		Fields:      map[string]interface{}{"value": 0.5, "crit": int64(100), "unit": `m"s`, "ok": true},
+		Timestamp:   time.Unix(1700000000, 0),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseInfluxdbLine() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseInfluxdbLineInvalid(t *testing.T) {
+	for _, line := range []string{"ping", "ping value", "ping value=x", "ping value=1 x"} {
+		if _, err := parseInfluxdbLine(line, ""); err == nil {
+			t.Errorf("parseInfluxdbLine(%q) should fail", line)
+		}
+	}
+}
+
+func TestParseGraphiteLine(t *testing.T) {
+	got, err := parseGraphiteLine("icinga2.host.ping.perfdata.rta.value 0.5 1700000000")
+	if err != nil {
+		t.Fatalf("parseGraphiteLine() error = %v", err)
+	}
+
+	want := services.GraphiteMetric{
+		Path:      "icinga2.host.ping.perfdata.rta.value",
+		Value:     0.5,
+		Timestamp: time.Unix(1700000000, 0),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGraphiteLine() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseOpenTsdbLine(t *testing.T) {
+	got, err := parseOpenTsdbLine("put icinga.host.rta 1700000000 0.5 host=foo type=host")
+	if err != nil {
+		t.Fatalf("parseOpenTsdbLine() error = %v", err)
+	}
+
+	want := services.OpenTsdbMetric{
+		Metric:    "icinga.host.rta",
+		Value:     0.5,
+		Timestamp: time.Unix(1700000000, 0),
+		Tags:      map[string]string{"host": "foo", "type": "host"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOpenTsdbLine() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseElasticsearchBulkPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		index string
+		bulk  bool
+	}{
+		{"/_bulk", "", true},
+		{"/icinga2/_bulk", "icinga2", true},
+		{"/icinga2/_doc/_bulk", "icinga2", true},
+		{"/icinga2/_doc/_bulk/", "icinga2", true},
+		{"/", "", false},
+		{"/icinga2", "", false},
+		{"/icinga2/_search", "", false},
+		{"/a/b/c/_bulk", "", false},
+	}
+
+	for _, test := range tests {
+		index, bulk := parseElasticsearchBulkPath(test.path)
+		if index != test.index || bulk != test.bulk {
+			t.Errorf("parseElasticsearchBulkPath(%q) = %q, %t, want %q, %t",
+				test.path, index, bulk, test.index, test.bulk)
+		}
+	}
+}
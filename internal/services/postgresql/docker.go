@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/icinga/icinga-testing/utils"
 	"go.uber.org/zap"
+	"io"
 	"time"
 )
 
@@ -59,13 +60,16 @@ func NewDockerCreator(logger *zap.Logger, dockerClient *client.Client, container
 	logger = logger.With(zap.String("container-id", cont.ID))
 	logger.Debug("created postgresql container")
 
+	output := &utils.LineRecorder{}
 	err = utils.ForwardDockerContainerOutput(context.Background(), dockerClient, cont.ID,
 		false, utils.NewLineWriter(func(line []byte) {
+			output.Add(line)
 			logger.Debug("container output", zap.ByteString("line", line))
 		}))
 	if err != nil {
 		logger.Fatal("failed to attach to container output", zap.Error(err))
 	}
+	watch := utils.DockerWatchContainer(dockerClient, cont.ID, output)
 
 	err = dockerClient.ContainerStart(context.Background(), cont.ID, types.ContainerStartOptions{})
 	if err != nil {
@@ -82,6 +86,10 @@ func NewDockerCreator(logger *zap.Logger, dockerClient *client.Client, container
 		containerId:    cont.ID,
 		containerName:  containerName,
 	}
+	d.rootConnection.exec = func(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error) {
+		return utils.DockerExecResult(ctx, dockerClient, logger, cont.ID, cmd, stdin)
+	}
+	d.rootConnection.watch = watch
 
 	db, err := d.rootConnection.openAsRoot("postgres")
 	defer func() { _ = db.Close() }()
@@ -100,6 +108,7 @@ func NewDockerCreator(logger *zap.Logger, dockerClient *client.Client, container
 }
 
 func (d *dockerCreator) Cleanup() {
+	d.watch.Expect()
 	err := d.client.ContainerRemove(context.Background(), d.containerId, types.ContainerRemoveOptions{
 		Force:         true,
 		RemoveVolumes: true,
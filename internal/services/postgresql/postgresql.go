@@ -1,11 +1,18 @@
 package postgresql
 
 import (
+	"context"
+	"errors"
 	"github.com/icinga/icinga-testing/services"
+	"github.com/icinga/icinga-testing/utils"
+	"io"
+	"time"
 )
 
 type Creator interface {
 	CreatePostgresqlDatabase() services.PostgresqlDatabaseBase
+	// CreateIcingaDbDatabase is like CreatePostgresqlDatabase, but the database already contains the Icinga DB schema.
+	CreateIcingaDbDatabase() services.PostgresqlDatabaseBase
 	Cleanup()
 }
 
@@ -43,6 +50,22 @@ type postgresqlDatabaseNopCleanup struct {
 	info
 }
 
+func (_ *postgresqlDatabaseNopCleanup) Exec(context.Context, []string, io.Reader) (utils.ExecResult, error) {
+	return utils.ExecResult{}, errors.New("exec is not supported on this connection")
+}
+
+func (_ *postgresqlDatabaseNopCleanup) ExitStatus() (utils.ExitStatus, bool) {
+	return utils.ExitStatus{}, false
+}
+
+func (_ *postgresqlDatabaseNopCleanup) Exited() <-chan struct{} {
+	return nil
+}
+
+func (_ *postgresqlDatabaseNopCleanup) Stop(context.Context, time.Duration) (utils.StopResult, error) {
+	return utils.StopResult{}, errors.New("stop is not supported on this connection")
+}
+
 func (_ *postgresqlDatabaseNopCleanup) Cleanup() {}
 
 var _ services.PostgresqlDatabaseBase = (*postgresqlDatabaseNopCleanup)(nil)
@@ -1,12 +1,17 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/icinga/icinga-testing/services"
 	"github.com/icinga/icinga-testing/utils"
 	_ "github.com/lib/pq"
+	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type rootConnection struct {
@@ -15,6 +20,13 @@ type rootConnection struct {
 	username string
 	password string
 	counter  uint32
+	// exec runs a command on the server, it is only set by a creator that started the server itself.
+	exec func(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+	// watch watches the server for exiting, it is only set by a creator that started the server itself.
+	watch *utils.DockerContainerWatch
+	// templateMutex protects templateReady, which is set once the template for CreateIcingaDbDatabase exists.
+	templateMutex sync.Mutex
+	templateReady bool
 }
 
 func newRootConnection(host string, port string, rootUsername string, rootPassword string) *rootConnection {
@@ -92,6 +104,34 @@ type rootConnectionDatabase struct {
 	server *rootConnection
 }
 
+func (d *rootConnectionDatabase) Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error) {
+	if d.server.exec == nil {
+		return utils.ExecResult{}, errors.New("exec is not supported on this connection")
+	}
+	return d.server.exec(ctx, cmd, stdin)
+}
+
+func (d *rootConnectionDatabase) ExitStatus() (utils.ExitStatus, bool) {
+	if d.server.watch == nil {
+		return utils.ExitStatus{}, false
+	}
+	return d.server.watch.ExitStatus()
+}
+
+func (d *rootConnectionDatabase) Exited() <-chan struct{} {
+	if d.server.watch == nil {
+		return nil
+	}
+	return d.server.watch.Done()
+}
+
+func (d *rootConnectionDatabase) Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error) {
+	if d.server.watch == nil {
+		return utils.StopResult{}, errors.New("stop is not supported on this connection")
+	}
+	return d.server.watch.Stop(ctx, timeout)
+}
+
 func (d *rootConnectionDatabase) Cleanup() {
 	db, err := d.server.openAsRoot("postgres")
 	if err != nil {
@@ -0,0 +1,145 @@
+package postgresql
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-testing/services"
+	"github.com/icinga/icinga-testing/utils"
+	"sync/atomic"
+)
+
+// templateName is used as the name of both the template database with the Icinga DB schema and the user owning the
+// objects within it.
+const templateName = "icingadb_template"
+
+// CreateIcingaDbDatabase creates a new database with the Icinga DB schema and a user to access it. The database is
+// created as a copy of a template database into which the schema is imported only once, on the first call.
+func (c *rootConnection) CreateIcingaDbDatabase() services.PostgresqlDatabaseBase {
+	c.ensureTemplate()
+
+	id := atomic.AddUint32(&c.counter, 1)
+	username := fmt.Sprintf("u%d", id)
+	password := utils.RandomString(16)
+	database := fmt.Sprintf("d%d", id)
+
+	db, err := c.openAsRoot("postgres")
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(fmt.Sprintf("CREATE USER %s WITH PASSWORD '%s'", username, password))
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s WITH TEMPLATE %s OWNER %s", database, templateName, username))
+	if err != nil {
+		panic(err)
+	}
+
+	// The objects within the copy are still owned by the template user, hand them over to the new user so that it
+	// can alter them like after importing the schema itself.
+	userDb, err := c.openAsRoot(database)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = userDb.Close() }()
+
+	_, err = userDb.Exec(fmt.Sprintf("REASSIGN OWNED BY %s TO %s", templateName, username))
+	if err != nil {
+		panic(err)
+	}
+
+	return &rootConnectionDatabase{
+		info: info{
+			host:     c.host,
+			port:     c.port,
+			username: username,
+			password: password,
+			database: database,
+		},
+		server: c,
+	}
+}
+
+// ensureTemplate prepares the template database unless this was already done successfully.
+func (c *rootConnection) ensureTemplate() {
+	c.templateMutex.Lock()
+	defer c.templateMutex.Unlock()
+
+	if !c.templateReady {
+		if err := c.prepareTemplate(); err != nil {
+			panic(err)
+		}
+		c.templateReady = true
+	}
+}
+
+// prepareTemplate creates the template database and imports the Icinga DB schema into it. Any leftovers of a previous
+// attempt that failed are removed first.
+//
+// The database itself is owned by root, only the objects within it are owned by the template user. This way, REASSIGN
+// OWNED in a copy only affects the objects of that copy and not the template database.
+func (c *rootConnection) prepareTemplate() error {
+	db, err := c.openAsRoot("postgres")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1", templateName)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", templateName))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("DROP USER IF EXISTS %s", templateName))
+	if err != nil {
+		return err
+	}
+
+	password := utils.RandomString(16)
+	_, err = db.Exec(fmt.Sprintf("CREATE USER %s WITH PASSWORD '%s'", templateName, password))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", templateName))
+	if err != nil {
+		return err
+	}
+
+	// The citext extension is required by Icinga DB.
+	err = c.createExtension(templateName, "citext")
+	if err != nil {
+		return err
+	}
+
+	templateDb, err := c.openAsRoot(templateName)
+	if err != nil {
+		return err
+	}
+	_, err = templateDb.Exec(fmt.Sprintf("GRANT ALL ON SCHEMA public TO %s", templateName))
+	_ = templateDb.Close()
+	if err != nil {
+		return err
+	}
+
+	template := services.PostgresqlDatabase{PostgresqlDatabaseBase: &postgresqlDatabaseNopCleanup{info{
+		host:     c.host,
+		port:     c.port,
+		username: templateName,
+		password: password,
+		database: templateName,
+	}}}
+	template.ImportIcingaDbSchema()
+
+	// CREATE DATABASE fails if there are other connections to its template, so terminate any that may still be
+	// closing and prevent new ones.
+	_, err = db.Exec(fmt.Sprintf("ALTER DATABASE %s WITH ALLOW_CONNECTIONS false", templateName))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1", templateName)
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/icinga/icinga-testing/services"
 	"github.com/icinga/icinga-testing/utils"
 	"go.uber.org/zap"
+	"io"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -71,8 +72,15 @@ func (r *dockerCreator) CreateRedisServer() services.RedisServerBase {
 	logger = logger.With(zap.String("container-id", cont.ID))
 	logger.Debug("started redis container")
 
+	s := &dockerServer{
+		redisDocker: r,
+		logger:      logger,
+		containerId: cont.ID,
+	}
+
 	err = utils.ForwardDockerContainerOutput(context.Background(), r.dockerClient, cont.ID,
 		false, utils.NewLineWriter(func(line []byte) {
+			s.output.Add(line)
 			logger.Debug("container output",
 				zap.ByteString("line", line))
 		}))
@@ -80,6 +88,7 @@ func (r *dockerCreator) CreateRedisServer() services.RedisServerBase {
 		logger.Fatal("failed to attach to container output",
 			zap.Error(err))
 	}
+	s.watch = utils.DockerWatchContainer(r.dockerClient, cont.ID, &s.output)
 
 	err = r.dockerClient.ContainerStart(context.Background(), cont.ID, types.ContainerStartOptions{})
 	if err != nil {
@@ -87,14 +96,9 @@ func (r *dockerCreator) CreateRedisServer() services.RedisServerBase {
 	}
 	logger.Debug("started container")
 
-	s := &dockerServer{
-		info: info{
-			host: utils.MustString(utils.DockerContainerAddress(context.Background(), r.dockerClient, cont.ID)),
-			port: "6379",
-		},
-		redisDocker: r,
-		logger:      logger,
-		containerId: cont.ID,
+	s.info = info{
+		host: utils.MustString(utils.DockerContainerAddress(context.Background(), r.dockerClient, cont.ID)),
+		port: "6379",
 	}
 
 	c := services.RedisServer{RedisServerBase: s}.Open()
@@ -153,6 +157,28 @@ type dockerServer struct {
 	redisDocker *dockerCreator
 	logger      *zap.Logger
 	containerId string
+	output      utils.LineRecorder
+	watch       *utils.DockerContainerWatch
+}
+
+func (s *dockerServer) Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error) {
+	return utils.DockerExecResult(ctx, s.redisDocker.dockerClient, s.logger, s.containerId, cmd, stdin)
+}
+
+func (s *dockerServer) ExitStatus() (utils.ExitStatus, bool) {
+	return s.watch.ExitStatus()
+}
+
+func (s *dockerServer) Exited() <-chan struct{} {
+	return s.watch.Done()
+}
+
+func (s *dockerServer) Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error) {
+	res, err := s.watch.Stop(ctx, timeout)
+	if err == nil {
+		s.logger.Debug("stopped container", zap.Int("exit-code", res.ExitCode), zap.Duration("duration", res.Duration))
+	}
+	return res, err
 }
 
 func (s *dockerServer) Cleanup() {
@@ -160,6 +186,7 @@ func (s *dockerServer) Cleanup() {
 	delete(s.redisDocker.running, s)
 	s.redisDocker.runningMutex.Unlock()
 
+	s.watch.Expect()
 	err := s.redisDocker.dockerClient.ContainerRemove(context.Background(), s.containerId, types.ContainerRemoveOptions{
 		Force:         true,
 		RemoveVolumes: true,
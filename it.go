@@ -10,9 +10,31 @@
 //   - ICINGA_TESTING_REDIS_IMAGE: Redis container image to use (default: "redis:latest")
 //   - ICINGA_TESTING_REDIS_MONITOR: If set to "1", log all Redis commands to the debug log using redis-cli monitor
 //   - ICINGA_TESTING_ICINGADB_BINARY: Path to the Icinga DB binary to test. It will run in a container and therefore
-//     must be compiled using CGO_ENABLED=0
+//     must be compiled using CGO_ENABLED=0, unless ICINGA_TESTING_ICINGADB_BASE_IMAGE is set to a compatible image
+//   - ICINGA_TESTING_ICINGADB_BASE_IMAGE: Container image to run ICINGA_TESTING_ICINGADB_BINARY in (default:
+//     "alpine:latest"). For example, binaries built using "go build -race" need a glibc based image like
+//     "debian:stable-slim". Data races reported by such binaries fail the test, see IT.IcingaDbInstanceT
+//   - ICINGA_TESTING_ICINGADB_SOURCE: Path to an Icinga DB source checkout to build and test if
+//     ICINGA_TESTING_ICINGADB_BINARY is not set. Builds are cached by the hash of the source tree and support
+//     running Icinga DB with a controlled clock (see IT.Clock)
+//   - ICINGA_TESTING_ICINGADB_BUILDER: How to build ICINGA_TESTING_ICINGADB_SOURCE, either "docker" to build in a
+//     container or "host" to use the Go toolchain of the host (default: "docker")
+//   - ICINGA_TESTING_ICINGADB_RACE: If set to "1", ICINGA_TESTING_ICINGADB_SOURCE is built using "go build -race".
+//     ICINGA_TESTING_ICINGADB_BASE_IMAGE has to be set to an image with a C library compatible with the builder then
+//   - ICINGA_TESTING_ICINGADB_COVERAGE: Path of a coverage profile to write. ICINGA_TESTING_ICINGADB_BINARY must be
+//     built using "go build -cover" then (ICINGA_TESTING_ICINGADB_SOURCE is built that way automatically). The
+//     coverage data of all instances is merged into the profile by IT.Cleanup, it can be viewed using "go tool cover"
+//   - ICINGA_TESTING_GOLANG_IMAGE: Go container image used by the "docker" builder (default: "golang:latest")
+//   - ICINGA_TESTING_ICINGADB_IMAGE: Icinga DB container image to use if ICINGA_TESTING_ICINGADB_BINARY is not set
+//     (default: "icinga/icingadb:latest")
 //   - ICINGA_TESTING_ICINGADB_SCHEMA_MYSQL: Path to the full Icinga DB schema file for MySQL/MariaDB
 //   - ICINGA_TESTING_ICINGADB_SCHEMA_PGSQL: Path to the full Icinga DB schema file for PostgreSQL
+//   - ICINGA_TESTING_IDO_SCHEMA_MYSQL: Path to the IDO schema file for MySQL/MariaDB (default: taken from the Icinga 2
+//     container image)
+//   - ICINGA_TESTING_IDO_SCHEMA_PGSQL: Path to the IDO schema file for PostgreSQL (default: taken from the Icinga 2
+//     container image)
+//   - ICINGA_TESTING_LIBFAKETIME: Path to a libfaketime.so.1 compatible with the Icinga 2 container image, required
+//     for running Icinga 2 nodes with a controlled clock (see IT.Clock)
 package icingatesting
 
 import (
@@ -24,10 +46,13 @@ import (
 	"github.com/icinga/icinga-testing/internal/services/icinga2"
 	"github.com/icinga/icinga-testing/internal/services/icingadb"
 	"github.com/icinga/icinga-testing/internal/services/mysql"
+	"github.com/icinga/icinga-testing/internal/services/perfdata"
 	"github.com/icinga/icinga-testing/internal/services/postgresql"
 	"github.com/icinga/icinga-testing/internal/services/redis"
 	"github.com/icinga/icinga-testing/services"
 	"github.com/icinga/icinga-testing/utils"
+	"github.com/icinga/icinga-testing/utils/faketime"
+	"github.com/icinga/icinga-testing/utils/pki"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
@@ -60,6 +85,10 @@ type IT struct {
 	redis           redis.Creator
 	icinga2         icinga2.Creator
 	icingaDb        icingadb.Creator
+	icingaDbImage   icingadb.Creator
+	perfdata        perfdata.Creator
+	clock           *faketime.Clock
+	ca              *pki.CA
 	logger          *zap.Logger
 	loggerDebugCore zapcore.Core
 }
@@ -178,10 +207,12 @@ func (it *IT) MysqlDatabase() services.MysqlDatabase {
 	return services.MysqlDatabase{MysqlDatabaseBase: it.getMysqlServer().CreateMysqlDatabase()}
 }
 
-// MysqlDatabaseT creates a new MySQL database and registers its cleanup function with testing.T.
+// MysqlDatabaseT creates a new MySQL database and registers its cleanup function with testing.T. If the MySQL server
+// exits unexpectedly, the test is marked as failed immediately.
 func (it *IT) MysqlDatabaseT(t testing.TB) services.MysqlDatabase {
 	m := it.MysqlDatabase()
 	t.Cleanup(m.Cleanup)
+	services.FailOnUnexpectedExit(t, "mysql", m)
 	return m
 }
 
@@ -193,13 +224,41 @@ func (it *IT) PostgresqlDatabase() services.PostgresqlDatabase {
 	return services.PostgresqlDatabase{PostgresqlDatabaseBase: it.getPostgresqlServer().CreatePostgresqlDatabase()}
 }
 
-// PostgresDatabaseT creates a new MySQL database and registers its cleanup function with testing.T.
+// PostgresDatabaseT creates a new MySQL database and registers its cleanup function with testing.T. If the
+// PostgreSQL server exits unexpectedly, the test is marked as failed immediately.
 func (it *IT) PostgresqlDatabaseT(t testing.TB) services.PostgresqlDatabase {
 	p := it.PostgresqlDatabase()
 	t.Cleanup(p.Cleanup)
+	services.FailOnUnexpectedExit(t, "postgresql", p)
 	return p
 }
 
+// IcingaDbDatabase creates a new database of the given kind, either "mysql" or "pgsql" like
+// RelationalDatabase.IcingaDbType, with the Icinga DB schema already imported.
+//
+// This is a faster alternative to calling ImportIcingaDbSchema on a new database: The schema is only imported once per
+// server into a template database. PostgreSQL databases are created as copies of it using CREATE DATABASE with the
+// TEMPLATE option, for MySQL, its tables and their rows are cloned into new databases.
+func (it *IT) IcingaDbDatabase(kind string) services.RelationalDatabase {
+	switch kind {
+	case "mysql":
+		return services.MysqlDatabase{MysqlDatabaseBase: it.getMysqlServer().CreateIcingaDbDatabase()}
+	case "pgsql":
+		return services.PostgresqlDatabase{PostgresqlDatabaseBase: it.getPostgresqlServer().CreateIcingaDbDatabase()}
+	default:
+		panic(fmt.Errorf("unknown database kind %q, must be mysql or pgsql", kind))
+	}
+}
+
+// IcingaDbDatabaseT creates a new database with the Icinga DB schema like IcingaDbDatabase and registers its cleanup
+// function with testing.T. If the database server exits unexpectedly, the test is marked as failed immediately.
+func (it *IT) IcingaDbDatabaseT(t testing.TB, kind string) services.RelationalDatabase {
+	d := it.IcingaDbDatabase(kind)
+	t.Cleanup(d.Cleanup)
+	services.FailOnUnexpectedExit(t, kind, d)
+	return d
+}
+
 func (it *IT) getRedis() redis.Creator {
 	it.mutex.Lock()
 	defer it.mutex.Unlock()
@@ -219,10 +278,12 @@ func (it *IT) RedisServer() services.RedisServer {
 	return services.RedisServer{RedisServerBase: it.getRedis().CreateRedisServer()}
 }
 
-// RedisServerT creates a new Redis server and registers its cleanup function with testing.T.
+// RedisServerT creates a new Redis server and registers its cleanup function with testing.T. If the server exits
+// unexpectedly, the test is marked as failed immediately.
 func (it *IT) RedisServerT(t testing.TB) services.RedisServer {
 	r := it.RedisServer()
 	t.Cleanup(r.Cleanup)
+	services.FailOnUnexpectedExit(t, "redis", r)
 	return r
 }
 
@@ -231,7 +292,11 @@ func (it *IT) getIcinga2() icinga2.Creator {
 	defer it.mutex.Unlock()
 
 	if it.icinga2 == nil {
-		it.icinga2 = icinga2.NewDockerCreator(it.logger, it.dockerClient, it.prefix+"-icinga2", it.dockerNetworkId)
+		if it.ca == nil {
+			it.ca = pki.MustNewCA()
+		}
+		it.icinga2 = icinga2.NewDockerCreator(it.logger, it.dockerClient, it.prefix+"-icinga2", it.dockerNetworkId,
+			it.ca)
 		it.deferCleanup(it.icinga2.Cleanup)
 	}
 
@@ -240,54 +305,268 @@ func (it *IT) getIcinga2() icinga2.Creator {
 
 // Icinga2Node creates a new Icinga 2 node.
 //
-// Each call to this function will spawn a dedicated Icinga 2 Docker container using the icinga/icinga2:edge image.
-func (it *IT) Icinga2Node(name string) services.Icinga2 {
-	return services.Icinga2{Icinga2Base: it.getIcinga2().CreateIcinga2(name)}
+// Each call to this function will spawn a dedicated Icinga 2 Docker container using the icinga/icinga2:edge image. The
+// certificate of the node is issued by a CA shared by all nodes of this IT instance, which is returned by its CA
+// function and used by its ApiClient function to verify the certificate.
+func (it *IT) Icinga2Node(name string, options ...services.Icinga2Option) services.Icinga2 {
+	return services.Icinga2{Icinga2Base: it.getIcinga2().CreateIcinga2(name, options...)}
 }
 
-// Icinga2NodeT creates a new Icinga 2 node and registers its cleanup function with testing.T.
-func (it *IT) Icinga2NodeT(t testing.TB, name string) services.Icinga2 {
-	n := it.Icinga2Node(name)
+// Icinga2NodeT creates a new Icinga 2 node and registers its cleanup function with testing.T. If the node exits
+// unexpectedly, the test is marked as failed immediately.
+func (it *IT) Icinga2NodeT(t testing.TB, name string, options ...services.Icinga2Option) services.Icinga2 {
+	n := it.Icinga2Node(name, options...)
 	t.Cleanup(n.Cleanup)
+	services.FailOnUnexpectedExit(t, "icinga2 node "+name, n)
 	return n
 }
 
+// Clock returns the clock shared by all containers of this IT instance that opted in to use it.
+//
+// To let an Icinga 2 node use this clock, pass services.WithIcinga2Clock(it.Clock()) when creating it. Icinga DB
+// instances built from ICINGA_TESTING_ICINGADB_SOURCE can use it by passing services.WithIcingaDbClock(it.Clock()).
+// Afterwards, it.Clock().Advance(d) moves the time of all these nodes and instances forward by d.
+func (it *IT) Clock() *faketime.Clock {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	if it.clock == nil {
+		c, err := faketime.NewClock()
+		if err != nil {
+			panic(err)
+		}
+		it.clock = c
+		it.deferCleanup(func() {
+			if err := c.Cleanup(); err != nil {
+				it.logger.Error("failed to remove faketime clock directory", zap.String("dir", c.Dir()), zap.Error(err))
+			}
+		})
+	}
+
+	return it.clock
+}
+
 func (it *IT) getIcingaDb() icingadb.Creator {
 	key := "ICINGA_TESTING_ICINGADB_BINARY"
+	sourceKey := "ICINGA_TESTING_ICINGADB_SOURCE"
 	path, ok := os.LookupEnv(key)
-	if !ok {
-		panic(fmt.Errorf("environment variable %s must be set", key))
+	source, hasSource := os.LookupEnv(sourceKey)
+	if !ok && !hasSource {
+		panic(fmt.Errorf("environment variable %s or %s must be set", key, sourceKey))
 	}
 
+	coverage := os.Getenv("ICINGA_TESTING_ICINGADB_COVERAGE")
+	race := os.Getenv("ICINGA_TESTING_ICINGADB_RACE") == "1"
+
 	it.mutex.Lock()
 	defer it.mutex.Unlock()
 
 	if it.icingaDb == nil {
+		if !ok {
+			builder := utils.GetEnvDefault("ICINGA_TESTING_ICINGADB_BUILDER", icingadb.BuilderDocker)
+			binary, err := icingadb.BuildBinary(context.Background(), it.logger, it.dockerClient, source, builder,
+				icingadb.BuildOptions{Cover: coverage != "", Race: race, Faketime: true})
+			if err != nil {
+				it.logger.Fatal("failed to build icingadb", zap.Error(err))
+			}
+			path = binary
+		}
+
 		it.icingaDb = icingadb.NewDockerBinaryCreator(it.logger, it.dockerClient, it.prefix+"-icingadb",
-			it.dockerNetworkId, path)
+			it.dockerNetworkId, path, coverage, !ok)
 		it.deferCleanup(it.icingaDb.Cleanup)
 	}
 
 	return it.icingaDb
 }
 
+func (it *IT) getIcingaDbImage() icingadb.Creator {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	if it.icingaDbImage == nil {
+		it.icingaDbImage = icingadb.NewDockerImageCreator(it.logger, it.dockerClient, it.prefix+"-icingadb-image",
+			it.dockerNetworkId)
+		it.deferCleanup(it.icingaDbImage.Cleanup)
+	}
+
+	return it.icingaDbImage
+}
+
 // IcingaDbInstance starts a new Icinga DB instance.
 //
 // It expects the ICINGA_TESTING_ICINGADB_BINARY environment variable to be set to the path of a precompiled icingadb
-// binary which is then started in a new Docker container when this function is called.
+// binary which is then started in a new Docker container when this function is called. Alternatively,
+// ICINGA_TESTING_ICINGADB_SOURCE can be set to an Icinga DB source checkout, which is then built once per IT.
+//
+// If the services.WithIcingaDbImage option is given, Icinga DB is started from that container image instead. If
+// neither ICINGA_TESTING_ICINGADB_BINARY nor ICINGA_TESTING_ICINGADB_SOURCE is set but ICINGA_TESTING_ICINGADB_IMAGE
+// is, all instances are started from that image.
 func (it *IT) IcingaDbInstance(redis services.RedisServer, rdb services.RelationalDatabase, options ...services.IcingaDbOption) services.IcingaDb {
-	return services.IcingaDb{IcingaDbBase: it.getIcingaDb().CreateIcingaDb(redis, rdb, options...)}
+	var idb services.IcingaDb
+	for _, option := range options {
+		option(&idb)
+	}
+
+	_, hasBinary := os.LookupEnv("ICINGA_TESTING_ICINGADB_BINARY")
+	_, hasSource := os.LookupEnv("ICINGA_TESTING_ICINGADB_SOURCE")
+	_, hasImage := os.LookupEnv("ICINGA_TESTING_ICINGADB_IMAGE")
+	if idb.Image() != "" || (!hasBinary && !hasSource && hasImage) {
+		idb.IcingaDbBase = it.getIcingaDbImage().CreateIcingaDb(redis, rdb, options...)
+	} else {
+		idb.IcingaDbBase = it.getIcingaDb().CreateIcingaDb(redis, rdb, options...)
+	}
+
+	return idb
 }
 
-// IcingaDbInstanceT creates a new Icinga DB instance and registers its cleanup function with testing.T.
+// IcingaDbInstanceT creates a new Icinga DB instance and registers its cleanup function with testing.T. If the
+// instance exits unexpectedly, i.e. not using Kill or Cleanup, the test is marked as failed immediately with its exit
+// code and last lines of output, see services.FailOnUnexpectedExit.
+//
+// When the test finishes, it is also marked as failed if the instance logged any errors, see
+// services.IcingaDb.AssertNoLogErrors and services.WithIcingaDbAllowedLogErrors, or reported data races, see
+// services.IcingaDb.AssertNoDataRaces.
 func (it *IT) IcingaDbInstanceT(
 	t testing.TB, redis services.RedisServer, rdb services.RelationalDatabase, options ...services.IcingaDbOption,
 ) services.IcingaDb {
 	i := it.IcingaDbInstance(redis, rdb, options...)
 	t.Cleanup(i.Cleanup)
+	services.FailOnUnexpectedExit(t, "icingadb", i)
+	t.Cleanup(func() {
+		i.AssertNoLogErrors(t)
+		i.AssertNoDataRaces(t)
+	})
 	return i
 }
 
+// IcingaDbHA starts n Icinga DB instances using the same Redis server and database, see IcingaDbInstance.
+func (it *IT) IcingaDbHA(
+	redis services.RedisServer, rdb services.RelationalDatabase, n int, options ...services.IcingaDbOption,
+) services.IcingaDbHA {
+	ha := make(services.IcingaDbHA, 0, n)
+	for i := 0; i < n; i++ {
+		ha = append(ha, it.IcingaDbInstance(redis, rdb, options...))
+	}
+	return ha
+}
+
+// IcingaDbHAT starts n Icinga DB instances using the same Redis server and database and registers their cleanup
+// functions with testing.T, see IcingaDbInstanceT. Unlike there, logged errors do not fail the test, as instances log
+// errors during handovers, for example when the responsible instance is killed or paused.
+//
+// Example usage:
+//
+//	ha := it.IcingaDbHAT(t, redis, rdb, 2)
+//	active, err := ha.WaitResponsible(ctx)
+//	require.NoError(t, err)
+//	require.NoError(t, active.Kill())
+//	_, err = ha.WaitTakeover(ctx, active)
+//	require.NoError(t, err)
+func (it *IT) IcingaDbHAT(
+	t testing.TB, redis services.RedisServer, rdb services.RelationalDatabase, n int,
+	options ...services.IcingaDbOption,
+) services.IcingaDbHA {
+	options = append([]services.IcingaDbOption{services.WithIcingaDbAllowedLogErrors()}, options...)
+
+	ha := make(services.IcingaDbHA, 0, n)
+	for i := 0; i < n; i++ {
+		ha = append(ha, it.IcingaDbInstanceT(t, redis, rdb, options...))
+	}
+	return ha
+}
+
+func (it *IT) getPerfdata() perfdata.Creator {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	if it.perfdata == nil {
+		gateway, err := utils.DockerNetworkGateway(context.Background(), it.dockerClient, it.dockerNetworkId)
+		if err != nil {
+			panic(err)
+		}
+		it.perfdata = perfdata.NewLocalCreator(it.logger, gateway)
+		it.deferCleanup(it.perfdata.Cleanup)
+	}
+
+	return it.perfdata
+}
+
+// GraphiteReceiver starts a new receiver for the Graphite plaintext protocol.
+//
+// The receiver runs within the test process and listens on the gateway address of the Docker network so that it can be
+// reached from the containers. Use Icinga2.EnableGraphiteWriter to send metrics to it.
+func (it *IT) GraphiteReceiver() services.GraphiteReceiver {
+	return services.GraphiteReceiver{GraphiteReceiverBase: it.getPerfdata().CreateGraphiteReceiver()}
+}
+
+// GraphiteReceiverT starts a new Graphite receiver and registers its cleanup function with testing.T.
+func (it *IT) GraphiteReceiverT(t testing.TB) services.GraphiteReceiver {
+	r := it.GraphiteReceiver()
+	t.Cleanup(r.Cleanup)
+	return r
+}
+
+// InfluxdbReceiver starts a new receiver for the InfluxDB 1.x and 2.x write APIs.
+//
+// The receiver runs within the test process and listens on the gateway address of the Docker network so that it can be
+// reached from the containers. Use Icinga2.EnableInfluxdbWriter or Icinga2.EnableInfluxdb2Writer to send points to it.
+func (it *IT) InfluxdbReceiver() services.InfluxdbReceiver {
+	return services.InfluxdbReceiver{InfluxdbReceiverBase: it.getPerfdata().CreateInfluxdbReceiver()}
+}
+
+// InfluxdbReceiverT starts a new InfluxDB receiver and registers its cleanup function with testing.T.
+func (it *IT) InfluxdbReceiverT(t testing.TB) services.InfluxdbReceiver {
+	r := it.InfluxdbReceiver()
+	t.Cleanup(r.Cleanup)
+	return r
+}
+
+// OpenTsdbReceiver starts a new receiver for the OpenTSDB telnet protocol.
+//
+// The receiver runs within the test process and listens on the gateway address of the Docker network so that it can be
+// reached from the containers. Use Icinga2.EnableOpenTsdbWriter to send metrics to it.
+func (it *IT) OpenTsdbReceiver() services.OpenTsdbReceiver {
+	return services.OpenTsdbReceiver{OpenTsdbReceiverBase: it.getPerfdata().CreateOpenTsdbReceiver()}
+}
+
+// OpenTsdbReceiverT starts a new OpenTSDB receiver and registers its cleanup function with testing.T.
+func (it *IT) OpenTsdbReceiverT(t testing.TB) services.OpenTsdbReceiver {
+	r := it.OpenTsdbReceiver()
+	t.Cleanup(r.Cleanup)
+	return r
+}
+
+// GelfReceiver starts a new receiver for GELF messages over TCP.
+//
+// The receiver runs within the test process and listens on the gateway address of the Docker network so that it can be
+// reached from the containers. Use Icinga2.EnableGelfWriter to send messages to it.
+func (it *IT) GelfReceiver() services.GelfReceiver {
+	return services.GelfReceiver{GelfReceiverBase: it.getPerfdata().CreateGelfReceiver()}
+}
+
+// GelfReceiverT starts a new GELF receiver and registers its cleanup function with testing.T.
+func (it *IT) GelfReceiverT(t testing.TB) services.GelfReceiver {
+	r := it.GelfReceiver()
+	t.Cleanup(r.Cleanup)
+	return r
+}
+
+// ElasticsearchReceiver starts a new receiver for the Elasticsearch bulk API.
+//
+// The receiver runs within the test process and listens on the gateway address of the Docker network so that it can be
+// reached from the containers. Use Icinga2.EnableElasticsearchWriter to send documents to it.
+func (it *IT) ElasticsearchReceiver() services.ElasticsearchReceiver {
+	return services.ElasticsearchReceiver{ElasticsearchReceiverBase: it.getPerfdata().CreateElasticsearchReceiver()}
+}
+
+// ElasticsearchReceiverT starts a new Elasticsearch receiver and registers its cleanup function with testing.T.
+func (it *IT) ElasticsearchReceiverT(t testing.TB) services.ElasticsearchReceiver {
+	r := it.ElasticsearchReceiver()
+	t.Cleanup(r.Cleanup)
+	return r
+}
+
 // Logger returns a *zap.Logger which additionally logs the current test case name.
 func (it *IT) Logger(t testing.TB) *zap.Logger {
 	cores := []zapcore.Core{zaptest.NewLogger(t, zaptest.WrapOptions(zap.IncreaseLevel(zap.InfoLevel))).Core()}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"github.com/icinga/icinga-testing/utils"
+	"testing"
+	"time"
+)
+
+// ExitWatcher is implemented by all services running as a process that may exit, for example because it crashed.
+type ExitWatcher interface {
+	// ExitStatus returns the exit status of the service and true if it exited, or false if it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the service exited.
+	Exited() <-chan struct{}
+}
+
+// FailOnUnexpectedExit marks t as failed as soon as s exits unexpectedly, i.e. not because icinga-testing stopped it,
+// reporting the exit code and the last lines of its output. Watching stops when the cleanup functions of t run, so
+// this must be called after registering the cleanup function of s with t.
+//
+// As only the goroutine running the test can stop it, the test itself continues, but the failure and its cause are
+// reported right away instead of only after some later check ran into a timeout.
+func FailOnUnexpectedExit(t testing.TB, name string, s ExitWatcher) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		select {
+		case <-s.Exited():
+			if status, _ := s.ExitStatus(); !status.Expected {
+				msg := ""
+				if status.Error != "" {
+					msg = " (" + status.Error + ")"
+				}
+				t.Errorf("%s exited unexpectedly with code %d%s%s", name, status.ExitCode, msg,
+					formatOutput(status.Output, utils.ExitOutputLines))
+			}
+		case <-stop:
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+}
+
+// Stopper is implemented by all services that can be stopped gracefully.
+type Stopper interface {
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+}
+
+// StopT stops s gracefully, see for example IcingaDbBase.Stop, and marks t as failed if the shutdown did not succeed
+// within timeout or resulted in a non-zero exit code. This allows testing the shutdown of a service, which is skipped
+// by its cleanup function.
+//
+// Example usage:
+//
+//	res := services.StopT(t, "icingadb", i, 10*time.Second)
+//	t.Logf("icingadb shut down in %s", res.Duration)
+func StopT(t testing.TB, name string, s Stopper, timeout time.Duration) utils.StopResult {
+	t.Helper()
+
+	res, err := s.Stop(context.Background(), timeout)
+	if err != nil {
+		t.Errorf("stopping %s failed: %v", name, err)
+	} else if res.TimedOut {
+		t.Errorf("%s did not shut down within %s and was killed%s", name, timeout,
+			formatOutput(res.Output, utils.ExitOutputLines))
+	} else if res.ExitCode != 0 {
+		t.Errorf("%s exited with code %d after %s%s", name, res.ExitCode, res.Duration,
+			formatOutput(res.Output, utils.ExitOutputLines))
+	}
+
+	return res
+}
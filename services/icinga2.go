@@ -2,18 +2,34 @@ package services
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/icinga/icinga-testing/internal"
 	"github.com/icinga/icinga-testing/utils"
+	"github.com/icinga/icinga-testing/utils/faketime"
+	"github.com/icinga/icinga-testing/utils/pki"
+	"io"
 	"net/http"
+	"testing"
 	"text/template"
 	"time"
 )
 
 type Icinga2Base interface {
+	// NodeName returns the name of the Icinga 2 node, which is also the name of its endpoint and the common name of
+	// its certificate.
+	NodeName() string
+
+	// CA returns the CA that issued the certificate of the node, or nil if the node uses a certificate not known to
+	// icinga-testing.
+	CA() *pki.CA
+
+	// Clock returns the clock controlling the time of the node, see WithIcinga2Clock, or nil if it uses the real time.
+	Clock() *faketime.Clock
+
 	// Host returns the host on which the Icinga 2 API can be reached.
 	Host() string
 
@@ -37,9 +53,45 @@ type Icinga2Base interface {
 	//   i.DeleteConfigGlob("etc/icinga2/zones.d/test/*.conf")
 	DeleteConfigGlob(glob string)
 
+	// ReadFile returns the contents of a regular file from the file system of the Icinga 2 node.
+	//
+	// Example usage:
+	//
+	//   i.ReadFile("var/lib/icinga2/icinga2.state")
+	ReadFile(file string) ([]byte, error)
+
+	// ListDir returns information on the files directly within a directory on the file system of the Icinga 2 node.
+	//
+	// Example usage:
+	//
+	//   i.ListDir("var/lib/icinga2/api/zones-stage")
+	ListDir(dir string) ([]utils.DockerFileInfo, error)
+
+	// CopyTo extracts a tar archive into a directory on the file system of the Icinga 2 node. File modes and ownership
+	// are taken from the archive.
+	CopyTo(dir string, tarball io.Reader) error
+
+	// CopyFrom returns a tar archive of a file or directory on the file system of the Icinga 2 node, preserving file
+	// modes and ownership. The caller must close the returned reader.
+	CopyFrom(path string) (io.ReadCloser, error)
+
+	// Exec runs a command in the container of the Icinga 2 node and returns its output and exit code. If stdin is not
+	// nil, it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
 	// EnableIcingaDb enables the icingadb feature on this node using the connection details of redis.
 	EnableIcingaDb(redis RedisServerBase)
 
+	// ExitStatus returns the exit status of the node and true if it exited, or false if it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the node exited.
+	Exited() <-chan struct{}
+
+	// Stop stops the node by sending SIGTERM and waits up to timeout for it to exit before killing it. The result
+	// contains its exit code and how long the shutdown took.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup stops the node and removes everything that was created to start this node.
 	Cleanup()
 }
@@ -47,12 +99,115 @@ type Icinga2Base interface {
 // Icinga2 wraps the Icinga2Base interface and adds some more helper functions.
 type Icinga2 struct {
 	Icinga2Base
+	clock *faketime.Clock
+}
+
+// Icinga2Option configures Icinga2.
+type Icinga2Option func(*Icinga2)
+
+// WithIcinga2Clock starts the Icinga 2 node using libfaketime so that its time is controlled by the given clock.
+//
+// This requires the ICINGA_TESTING_LIBFAKETIME environment variable to be set to the path of a libfaketime.so.1 that
+// is compatible with the C library of the Icinga 2 container image.
+func WithIcinga2Clock(clock *faketime.Clock) func(*Icinga2) {
+	return func(i *Icinga2) {
+		i.clock = clock
+	}
 }
 
+// Clock returns the clock controlling the time of the node, see Icinga2Base.Clock. Until the node is created, it
+// returns the clock set using WithIcinga2Clock, which is how creators obtain it from the options.
+func (i Icinga2) Clock() *faketime.Clock {
+	if i.clock != nil || i.Icinga2Base == nil {
+		return i.clock
+	}
+	return i.Icinga2Base.Clock()
+}
+
+// ApiClient returns a client for the Icinga 2 API authenticated as an ApiUser with all permissions. If the CA of the
+// node is known, the certificate of the node is verified against it.
 func (i Icinga2) ApiClient() *utils.Icinga2Client {
 	return utils.NewIcinga2Client(i.Host()+":"+i.Port(),
 		internal.Icinga2DefaultUsername,
-		internal.Icinga2DefaultPassword)
+		internal.Icinga2DefaultPassword,
+		i.apiClientOptions()...)
+}
+
+// apiClientOptions returns the options for verifying the certificate of the node if its CA is known.
+func (i Icinga2) apiClientOptions() []utils.Icinga2ClientOption {
+	if ca := i.CA(); ca != nil {
+		return []utils.Icinga2ClientOption{utils.WithIcinga2ClientRootCA(ca.Certificate, i.NodeName())}
+	}
+	return nil
+}
+
+// CertificateApiClient creates an ApiUser with all permissions that is authenticated by a client certificate with the
+// given common name, reloads the node and returns a client using such a certificate issued by the CA of the node
+// instead of basic auth.
+func (i Icinga2) CertificateApiClient(cn string) (*utils.Icinga2Client, error) {
+	ca := i.CA()
+	if ca == nil {
+		return nil, errors.New("the CA of the icinga2 node is unknown")
+	}
+
+	cert, err := ca.NewCertificate(cn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like CreateApiUser, the user is written to a config file, so it is kept across restarts of the node.
+	i.WriteConfig(apiUserConfigFile(cn), []byte(fmt.Sprintf(`
+		object ApiUser %s {
+			client_cn = %s
+			permissions = [ "*" ]
+		}
+	`, icinga2String(cn), icinga2String(cn))))
+
+	if err := i.Reload(); err != nil {
+		return nil, fmt.Errorf("failed to reload icinga2 after creating api user %q: %w", cn, err)
+	}
+
+	options := append(i.apiClientOptions(), utils.WithIcinga2ClientCertificate(cert))
+	return utils.NewIcinga2Client(i.Host()+":"+i.Port(), "", "", options...), nil
+}
+
+// Version returns the version of Icinga 2 as reported by the /v1/status/IcingaApplication API endpoint.
+func (i Icinga2) Version() (utils.Version, error) {
+	res, err := i.ApiClient().GetJson("/v1/status/IcingaApplication")
+	if err != nil {
+		return utils.Version{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return utils.Version{}, fmt.Errorf("request for icinga2 status failed with HTTP %s", res.Status)
+	}
+
+	var data struct {
+		Results []struct {
+			Status struct {
+				IcingaApplication struct {
+					App struct {
+						Version string `json:"version"`
+					} `json:"app"`
+				} `json:"icingaapplication"`
+			} `json:"status"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return utils.Version{}, err
+	}
+	if len(data.Results) != 1 {
+		return utils.Version{}, fmt.Errorf("expected one result from icinga2 status, got %d", len(data.Results))
+	}
+
+	return utils.ParseVersion(data.Results[0].Status.IcingaApplication.App.Version)
+}
+
+// RequireVersion skips the test if the version of Icinga 2 does not satisfy the given constraint, for example
+// ">= 2.14". See utils.Version.Satisfies for the supported constraints.
+func (i Icinga2) RequireVersion(t testing.TB, constraint string) {
+	t.Helper()
+	requireVersion(t, "Icinga 2", i.Version, constraint)
 }
 
 // Reload sends a reload signal to icinga2 and waits for the new config to become active.
@@ -116,6 +271,47 @@ func (i Icinga2) Ping() error {
 	return nil
 }
 
+// InstallCA replaces the CA certificate trusted by the node with the one of the given CA and issues a new certificate
+// for the node from it. The node has to be reloaded for the change to take effect.
+//
+// Nodes created by IT already use a CA created by it, which is returned by CA, so this is only needed to let a node use
+// a different CA. Afterwards, the node accepts cluster connections from endpoints presenting a certificate issued by
+// ca, see package utils/cluster.
+func (i Icinga2) InstallCA(ca *pki.CA) {
+	cert, err := ca.NewCertificate(i.NodeName())
+	if err != nil {
+		panic(err)
+	}
+
+	i.WriteConfig("var/lib/icinga2/certs/ca.crt", ca.CertificateToPem())
+	i.WriteConfig("var/lib/icinga2/certs/"+i.NodeName()+".crt", cert.CertificateToPem())
+	i.WriteConfig("var/lib/icinga2/certs/"+i.NodeName()+".key", cert.KeyToPem())
+}
+
+// WriteClusterEndpointConfig declares an additional endpoint in its own zone. If parent is not empty, the zone is a
+// child of the given zone. The zone of the node itself is called "master". The node has to be reloaded for the change
+// to take effect.
+//
+// Example usage, allowing a fake endpoint connected using package utils/cluster to send check results for hosts that
+// are placed in the zone "satellite":
+//
+//	i.WriteClusterEndpointConfig("fake", "satellite", "master")
+func (i Icinga2) WriteClusterEndpointConfig(endpoint string, zone string, parent string) {
+	parentAttr := ""
+	if parent != "" {
+		parentAttr = "parent = " + icinga2String(parent)
+	}
+
+	i.WriteConfig(fmt.Sprintf("etc/icinga2/conf.d/icinga-testing-endpoint-%s.conf", endpoint), []byte(fmt.Sprintf(`
+		object Endpoint %s {}
+
+		object Zone %s {
+			endpoints = [ %s ]
+			%s
+		}
+	`, icinga2String(endpoint), icinga2String(zone), icinga2String(endpoint), parentAttr)))
+}
+
 //go:embed icinga2_icingadb.conf
 var icinga2IcingaDbConfRawTemplate string
 var icinga2IcingaDbConfTemplate = template.Must(template.New("icingadb.conf").Parse(icinga2IcingaDbConfRawTemplate))
@@ -128,3 +324,104 @@ func (i Icinga2) WriteIcingaDbConf(r RedisServerBase) {
 	}
 	i.WriteConfig(fmt.Sprintf("etc/icinga2/features-enabled/icingadb_%s_%s.conf", r.Host(), r.Port()), b.Bytes())
 }
+
+// perfdataFeatureFile returns the file name used to write the config of a perfdata writer feature that sends its
+// data to the given host and port.
+func perfdataFeatureFile(feature string, host string, port string) string {
+	return fmt.Sprintf("etc/icinga2/features-enabled/%s_%s_%s.conf", feature, host, port)
+}
+
+// EnableGraphiteWriter writes a GraphiteWriter object sending metrics to the given receiver. The node has to be
+// reloaded for the change to take effect.
+func (i Icinga2) EnableGraphiteWriter(r GraphiteReceiverBase) {
+	i.WriteConfig(perfdataFeatureFile("graphite", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object GraphiteWriter %s {
+			host = %s
+			port = %s
+			enable_send_thresholds = true
+			enable_send_metadata = true
+		}
+	`, icinga2String("graphite_"+r.Host()+"_"+r.Port()), icinga2String(r.Host()), icinga2String(r.Port()))))
+}
+
+// EnableInfluxdbWriter writes an InfluxdbWriter object sending points to the InfluxDB 1.x write API of the given
+// receiver using the given database name. The node has to be reloaded for the change to take effect.
+func (i Icinga2) EnableInfluxdbWriter(r InfluxdbReceiverBase, database string) {
+	i.WriteConfig(perfdataFeatureFile("influxdb", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object InfluxdbWriter %s {
+			host = %s
+			port = %s
+			database = %s
+			flush_interval = 1s
+			flush_threshold = 1
+			enable_send_thresholds = true
+			enable_send_metadata = true
+		}
+	`,
+		icinga2String("influxdb_"+r.Host()+"_"+r.Port()),
+		icinga2String(r.Host()),
+		icinga2String(r.Port()),
+		icinga2String(database),
+	)))
+}
+
+// EnableInfluxdb2Writer writes an Influxdb2Writer object sending points to the InfluxDB 2.x write API of the given
+// receiver using the given organization and bucket. The node has to be reloaded for the change to take effect.
+func (i Icinga2) EnableInfluxdb2Writer(r InfluxdbReceiverBase, organization string, bucket string) {
+	i.WriteConfig(perfdataFeatureFile("influxdb2", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object Influxdb2Writer %s {
+			host = %s
+			port = %s
+			organization = %s
+			bucket = %s
+			auth_token = "icinga-testing"
+			flush_interval = 1s
+			flush_threshold = 1
+			enable_send_thresholds = true
+			enable_send_metadata = true
+		}
+	`,
+		icinga2String("influxdb2_"+r.Host()+"_"+r.Port()),
+		icinga2String(r.Host()),
+		icinga2String(r.Port()),
+		icinga2String(organization),
+		icinga2String(bucket),
+	)))
+}
+
+// EnableOpenTsdbWriter writes an OpenTsdbWriter object sending metrics to the given receiver. The node has to be
+// reloaded for the change to take effect.
+func (i Icinga2) EnableOpenTsdbWriter(r OpenTsdbReceiverBase) {
+	i.WriteConfig(perfdataFeatureFile("opentsdb", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object OpenTsdbWriter %s {
+			host = %s
+			port = %s
+		}
+	`, icinga2String("opentsdb_"+r.Host()+"_"+r.Port()), icinga2String(r.Host()), icinga2String(r.Port()))))
+}
+
+// EnableGelfWriter writes a GelfWriter object sending messages to the given receiver. The node has to be reloaded
+// for the change to take effect.
+func (i Icinga2) EnableGelfWriter(r GelfReceiverBase) {
+	i.WriteConfig(perfdataFeatureFile("gelf", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object GelfWriter %s {
+			host = %s
+			port = %s
+			enable_send_perfdata = true
+		}
+	`, icinga2String("gelf_"+r.Host()+"_"+r.Port()), icinga2String(r.Host()), icinga2String(r.Port()))))
+}
+
+// EnableElasticsearchWriter writes an ElasticsearchWriter object sending documents to the given receiver. The node
+// has to be reloaded for the change to take effect.
+func (i Icinga2) EnableElasticsearchWriter(r ElasticsearchReceiverBase) {
+	i.WriteConfig(perfdataFeatureFile("elasticsearch", r.Host(), r.Port()), []byte(fmt.Sprintf(`
+		object ElasticsearchWriter %s {
+			host = %s
+			port = %s
+			enable_send_perfdata = true
+			flush_interval = 1s
+			flush_threshold = 1
+		}
+	`, icinga2String("elasticsearch_"+r.Host()+"_"+r.Port()), icinga2String(r.Host()), icinga2String(r.Port()))))
+}
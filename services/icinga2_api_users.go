@@ -0,0 +1,100 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"strings"
+)
+
+// Permission is a single entry of the permissions attribute of an Icinga 2 ApiUser.
+//
+// https://icinga.com/docs/icinga-2/latest/doc/12-icinga2-api/#permissions
+type Permission struct {
+	// Permission is the name of the permission, for example "objects/query/Host" or "actions/*".
+	Permission string
+
+	// Filter is an optional Icinga 2 DSL expression restricting the objects the permission applies to. It is used as
+	// the body of the filter function, so the object is available as host, service, etc. depending on its type, for
+	// example `host.vars.os == "Linux"`.
+	Filter string
+}
+
+// String returns the permission in the Icinga 2 DSL. The filter is inserted as is, so it must not contain "}}".
+func (p Permission) String() string {
+	if p.Filter == "" {
+		return icinga2String(p.Permission)
+	}
+	return fmt.Sprintf("{ permission = %s, filter = {{ %s }} }", icinga2String(p.Permission), p.Filter)
+}
+
+// icinga2String returns s as an Icinga 2 DSL string literal. Unlike Go, the DSL only supports the escape sequences
+// \", \\, \b, \f, \n, \r, \t and octal ones, and takes all other bytes, including non-ASCII ones, literally.
+func icinga2String(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				_, _ = fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// CreateApiUser creates an ApiUser with the given permissions, reloads the node and returns a client authenticated
+// as this user.
+//
+// Example usage:
+//
+//	c, err := i.CreateApiUser("linux-only", []services.Permission{
+//		{Permission: "objects/query/Host", Filter: `host.vars.os == "Linux"`},
+//		{Permission: "status/query"},
+//	})
+func (i Icinga2) CreateApiUser(name string, permissions []Permission) (*utils.Icinga2Client, error) {
+	password := utils.RandomString(16)
+
+	rendered := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		rendered = append(rendered, p.String())
+	}
+
+	i.WriteConfig(apiUserConfigFile(name), []byte(fmt.Sprintf(`
+		object ApiUser %s {
+			password = %s
+			permissions = [ %s ]
+		}
+	`, icinga2String(name), icinga2String(password), strings.Join(rendered, ", "))))
+
+	if err := i.Reload(); err != nil {
+		return nil, fmt.Errorf("failed to reload icinga2 after creating api user %q: %w", name, err)
+	}
+
+	return utils.NewIcinga2Client(i.Host()+":"+i.Port(), name, password, i.apiClientOptions()...), nil
+}
+
+// apiUserConfigFile returns the path of the config file for the ApiUser with the given name. The name is hashed as it
+// may contain characters that are not allowed in or have a special meaning within a path, like "/".
+func apiUserConfigFile(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return fmt.Sprintf("etc/icinga2/conf.d/icinga-testing-api-user-%s.conf", hex.EncodeToString(h[:]))
+}
@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestPermissionString(t *testing.T) {
+	tests := []struct {
+		permission Permission
+		want       string
+	}{{
+		permission: Permission{Permission: "status/query"},
+		want:       `"status/query"`,
+	}, {
+		permission: Permission{Permission: "objects/query/Host", Filter: `host.vars.os == "Linux"`},
+		want:       `{ permission = "objects/query/Host", filter = {{ host.vars.os == "Linux" }} }`,
+	}, {
+		// The filter is DSL code and must be inserted without escaping quotes and backslashes.
+		permission: Permission{Permission: "objects/query/Host", Filter: `match("C:\\*", host.vars.path)`},
+		want:       `{ permission = "objects/query/Host", filter = {{ match("C:\\*", host.vars.path) }} }`,
+	}, {
+		permission: Permission{Permission: `a"b\c`},
+		want:       `"a\"b\\c"`,
+	}, {
+		// Non-ASCII characters are taken literally, Go's \u and \x escapes are not supported by the DSL.
+		permission: Permission{Permission: "objects/query/Host/Zürich\x01\n"},
+		want:       `"objects/query/Host/Zürich\001\n"`,
+	}}
+
+	for _, test := range tests {
+		if got := test.permission.String(); got != test.want {
+			t.Errorf("%#v.String() = %s, want %s", test.permission, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"net/http"
+	"os"
+	"time"
+)
+
+// idoSchemaFile returns the IDO schema for the given database type. It is read from the file given by the environment
+// variable key if set, otherwise from the given path on the file system of the Icinga 2 node.
+func (i Icinga2) idoSchemaFile(key string, path string) (string, error) {
+	if file, ok := os.LookupEnv(key); ok {
+		schema, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read ido schema file %q: %w", file, err)
+		}
+		return string(schema), nil
+	}
+
+	schema, err := i.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ido schema from icinga2 node (set %s to use a local file): %w", key, err)
+	}
+	return string(schema), nil
+}
+
+// EnableIdoMysql imports the IDO schema into the given database and writes an IdoMysqlConnection object writing to
+// it. The node has to be reloaded for the change to take effect, use WaitForIdoConnected to wait until the IDO is
+// connected afterwards.
+//
+// The schema is taken from the Icinga 2 node or, if set, from the file given by the ICINGA_TESTING_IDO_SCHEMA_MYSQL
+// environment variable.
+func (i Icinga2) EnableIdoMysql(m MysqlDatabase) error {
+	schema, err := i.idoSchemaFile("ICINGA_TESTING_IDO_SCHEMA_MYSQL", "usr/share/icinga2-ido-mysql/schema/mysql.sql")
+	if err != nil {
+		return err
+	}
+	if err := m.ImportSchema(schema); err != nil {
+		return fmt.Errorf("failed to import ido schema: %w", err)
+	}
+
+	i.WriteConfig(idoFeatureFile("ido-mysql", m.Host(), m.Port(), m.Database()), []byte(fmt.Sprintf(`
+		object IdoMysqlConnection %s {
+			host = %s
+			port = %s
+			user = %s
+			password = %s
+			database = %s
+		}
+	`,
+		icinga2String("ido-mysql_"+m.Host()+"_"+m.Port()+"_"+m.Database()),
+		icinga2String(m.Host()),
+		m.Port(),
+		icinga2String(m.Username()),
+		icinga2String(m.Password()),
+		icinga2String(m.Database()),
+	)))
+
+	return nil
+}
+
+// EnableIdoPgsql imports the IDO schema into the given database and writes an IdoPgsqlConnection object writing to
+// it. The node has to be reloaded for the change to take effect, use WaitForIdoConnected to wait until the IDO is
+// connected afterwards.
+//
+// The schema is taken from the Icinga 2 node or, if set, from the file given by the ICINGA_TESTING_IDO_SCHEMA_PGSQL
+// environment variable.
+func (i Icinga2) EnableIdoPgsql(p PostgresqlDatabase) error {
+	schema, err := i.idoSchemaFile("ICINGA_TESTING_IDO_SCHEMA_PGSQL", "usr/share/icinga2-ido-pgsql/schema/pgsql.sql")
+	if err != nil {
+		return err
+	}
+	if err := p.ImportSchema(schema); err != nil {
+		return fmt.Errorf("failed to import ido schema: %w", err)
+	}
+
+	i.WriteConfig(idoFeatureFile("ido-pgsql", p.Host(), p.Port(), p.Database()), []byte(fmt.Sprintf(`
+		object IdoPgsqlConnection %s {
+			host = %s
+			port = %s
+			user = %s
+			password = %s
+			database = %s
+		}
+	`,
+		icinga2String("ido-pgsql_"+p.Host()+"_"+p.Port()+"_"+p.Database()),
+		icinga2String(p.Host()),
+		p.Port(),
+		icinga2String(p.Username()),
+		icinga2String(p.Password()),
+		icinga2String(p.Database()),
+	)))
+
+	return nil
+}
+
+// idoFeatureFile returns the file name used to write the config of an IDO feature writing to the given database.
+func idoFeatureFile(feature string, host string, port string, database string) string {
+	return fmt.Sprintf("etc/icinga2/features-enabled/%s_%s_%s_%s.conf", feature, host, port, database)
+}
+
+// WaitForIdoConnected waits until all IdoMysqlConnection and IdoPgsqlConnection objects of the node report to be
+// connected to their database. It fails if there are no such objects.
+func (i Icinga2) WaitForIdoConnected(ctx context.Context) error {
+	c := i.ApiClient()
+	interval := time.NewTicker(100 * time.Millisecond)
+	defer interval.Stop()
+
+	var err error
+	for {
+		var connections, connected int
+		for _, plural := range []string{"idomysqlconnections", "idopgsqlconnections"} {
+			var total, ok int
+			total, ok, err = idoConnections(ctx, c, plural)
+			if err != nil {
+				break
+			}
+			connections += total
+			connected += ok
+		}
+
+		if err == nil {
+			if connections == 0 {
+				err = errors.New("no ido connections found")
+			} else if connected < connections {
+				err = fmt.Errorf("%d of %d ido connections are connected", connected, connections)
+			} else {
+				return nil
+			}
+		}
+
+		select {
+		case <-interval.C:
+		case <-ctx.Done():
+			return fmt.Errorf("ido did not connect: %w", err)
+		}
+	}
+}
+
+// idoConnections returns the number of all and of connected IDO connection objects of the given type.
+func idoConnections(ctx context.Context, c *utils.Icinga2Client, plural string) (int, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/objects/"+plural+"?attrs=connected", nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	// Icinga 2 responds with 404 if there are no objects of this type or the IDO library is not loaded.
+	if res.StatusCode == http.StatusNotFound {
+		return 0, 0, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("request for %s failed with HTTP %s", plural, res.Status)
+	}
+
+	var data struct {
+		Results []struct {
+			Attrs struct {
+				Connected bool `json:"connected"`
+			} `json:"attrs"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return 0, 0, err
+	}
+
+	connected := 0
+	for _, r := range data.Results {
+		if r.Attrs.Connected {
+			connected++
+		}
+	}
+
+	return len(data.Results), connected, nil
+}
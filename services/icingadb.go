@@ -1,9 +1,17 @@
 package services
 
 import (
-	_ "embed"
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"github.com/icinga/icinga-testing/utils/faketime"
+	"gopkg.in/yaml.v3"
 	"io"
-	"text/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
 )
 
 type IcingaDbBase interface {
@@ -13,6 +21,47 @@ type IcingaDbBase interface {
 	// RelationalDatabase returns the instance information of the relational database this instance is using.
 	RelationalDatabase() RelationalDatabase
 
+	// Exec runs a command in the container of the instance and returns its output and exit code. If stdin is not nil,
+	// it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
+	// Output returns all lines the instance has written to stdout and stderr so far.
+	Output() []string
+
+	// OutputSince returns the lines the instance has written after the first n ones and the total number of lines
+	// written so far, which can be passed as n to the next call to get only the lines written in the meantime.
+	OutputSince(n int) ([]string, int)
+
+	// Clock returns the clock controlling the time of the instance, see WithIcingaDbClock, or nil if it uses the real
+	// time.
+	Clock() *faketime.Clock
+
+	// LogsSince is like OutputSince, but returns the lines parsed using ParseIcingaDbLogLine. Each line is only parsed
+	// once when it is written, so this is cheaper than parsing the result of Output repeatedly.
+	LogsSince(n int) ([]IcingaDbLogEntry, int)
+
+	// ExitStatus returns the exit status of the instance and true if it exited, or false if it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the instance exited.
+	Exited() <-chan struct{}
+
+	// Kill kills the instance using SIGKILL without giving it the chance to shut down.
+	Kill() error
+
+	// Pause freezes all processes of the instance until Unpause is called.
+	Pause() error
+
+	// Unpause resumes the processes of the instance frozen by Pause.
+	Unpause() error
+
+	// Paused returns whether the instance is currently paused.
+	Paused() bool
+
+	// Stop stops the instance by sending SIGTERM and waits up to timeout for it to exit before killing it. The result
+	// contains its exit code and how long the shutdown took. A paused instance is unpaused first.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup stops the instance and removes everything that was created to start it.
 	Cleanup()
 }
@@ -20,15 +69,75 @@ type IcingaDbBase interface {
 // IcingaDb wraps the IcingaDbBase interface and adds some more helper functions.
 type IcingaDb struct {
 	IcingaDbBase
-	config string
+	config      string
+	typedConfig IcingaDbConfig
+	image       string
+	clock       *faketime.Clock
+
+	allowedLogErrors  []*regexp.Regexp
+	allowAllLogErrors bool
 }
 
-//go:embed icingadb.yml
-var icingadbYmlRawTemplate string
-var icingadbYmlTemplate = template.Must(template.New("icingadb.yml").Parse(icingadbYmlRawTemplate))
+// DefaultConfig returns the configuration icinga-testing uses for the instance before applying WithIcingaDbTypedConfig
+// and WithIcingaDbConfig, containing the connection details of the database and Redis server and debug logging. The
+// high-availability component always logs debug messages by default, as IcingaDb.InstanceId relies on them.
+func (i IcingaDb) DefaultConfig() (IcingaDbConfig, error) {
+	rdb := i.RelationalDatabase()
+	dbPort, err := strconv.Atoi(rdb.Port())
+	if err != nil {
+		return IcingaDbConfig{}, fmt.Errorf("invalid database port %q: %w", rdb.Port(), err)
+	}
+
+	redis := i.Redis()
+	redisPort, err := strconv.Atoi(redis.Port())
+	if err != nil {
+		return IcingaDbConfig{}, fmt.Errorf("invalid redis port %q: %w", redis.Port(), err)
+	}
+
+	interval := time.Second
+	return IcingaDbConfig{
+		Database: IcingaDbDatabaseConfig{
+			Type:     rdb.IcingaDbType(),
+			Host:     rdb.Host(),
+			Port:     dbPort,
+			Database: rdb.Database(),
+			User:     rdb.Username(),
+			Password: rdb.Password(),
+		},
+		Redis: IcingaDbRedisConfig{
+			Host: redis.Host(),
+			Port: redisPort,
+		},
+		Logging: IcingaDbLoggingConfig{
+			Level:    "debug",
+			Interval: &interval,
+			Options:  map[string]string{"high-availability": "debug"},
+		},
+	}, nil
+}
 
+// WriteConfig writes the config.yml for the instance. It consists of DefaultConfig merged with the configs set using
+// WithIcingaDbTypedConfig, followed by the raw YAML set using WithIcingaDbConfig.
 func (i IcingaDb) WriteConfig(w io.Writer) error {
-	return icingadbYmlTemplate.Execute(w, i)
+	config, err := i.DefaultConfig()
+	if err != nil {
+		return err
+	}
+	config.Merge(i.typedConfig)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(config); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if i.config != "" {
+		_, err = fmt.Fprintln(w, i.config)
+	}
+	return err
 }
 
 // Config returns additional raw YAML configuration, if any.
@@ -36,12 +145,103 @@ func (i IcingaDb) Config() string {
 	return i.config
 }
 
+// TypedConfig returns the configuration set using WithIcingaDbTypedConfig.
+func (i IcingaDb) TypedConfig() IcingaDbConfig {
+	return i.typedConfig
+}
+
 // IcingaDbOption configures IcingaDb.
 type IcingaDbOption func(*IcingaDb)
 
-// WithIcingaDbConfig sets additional raw YAML configuration.
+// WithIcingaDbConfig sets additional raw YAML configuration. It is appended to the rendered configuration as is, so
+// it must not contain keys that are already set. Prefer WithIcingaDbTypedConfig.
 func WithIcingaDbConfig(config string) func(*IcingaDb) {
 	return func(db *IcingaDb) {
 		db.config = config
 	}
 }
+
+// WithIcingaDbTypedConfig merges the given configuration into the configuration of the instance, overriding the
+// defaults set by icinga-testing. It can be given multiple times, see IcingaDbConfig.Merge.
+//
+// Example usage:
+//
+//	days := 7
+//	services.WithIcingaDbTypedConfig(services.IcingaDbConfig{
+//		Logging:   services.IcingaDbLoggingConfig{Level: "info", Options: map[string]string{"history-sync": "debug"}},
+//		Retention: services.IcingaDbRetentionConfig{HistoryDays: &days},
+//	})
+func WithIcingaDbTypedConfig(config IcingaDbConfig) func(*IcingaDb) {
+	return func(db *IcingaDb) {
+		db.typedConfig.Merge(config)
+	}
+}
+
+// Image returns the container image set using WithIcingaDbImage, if any.
+func (i IcingaDb) Image() string {
+	return i.image
+}
+
+// WithIcingaDbImage starts Icinga DB from the given container image, for example "icinga/icingadb:1.1.0", instead of
+// the binary under test. This allows testing released versions next to the binary under test.
+func WithIcingaDbImage(image string) func(*IcingaDb) {
+	return func(db *IcingaDb) {
+		db.image = image
+	}
+}
+
+// WithIcingaDbClock starts the Icinga DB instance so that its time is controlled by the given clock.
+//
+// Unlike Icinga 2, Icinga DB does not use libfaketime, which cannot intercept how Go programs read the time. Instead,
+// this requires a binary built from ICINGA_TESTING_ICINGADB_SOURCE, which adds the offset of the clock to the result
+// of time.Now. Timers and sleeps keep using the real time, so for example the heartbeat is still written every second.
+func WithIcingaDbClock(clock *faketime.Clock) func(*IcingaDb) {
+	return func(db *IcingaDb) {
+		db.clock = clock
+	}
+}
+
+// Clock returns the clock controlling the time of the instance, see IcingaDbBase.Clock. Until the instance is
+// created, it returns the clock set using WithIcingaDbClock, which is how creators obtain it from the options.
+func (i IcingaDb) Clock() *faketime.Clock {
+	if i.clock != nil || i.IcingaDbBase == nil {
+		return i.clock
+	}
+	return i.IcingaDbBase.Clock()
+}
+
+// now returns the current time as seen by the instance, taking the offset of its clock into account.
+func (i IcingaDb) now() time.Time {
+	if clock := i.Clock(); clock != nil {
+		return time.Now().Add(clock.Offset())
+	}
+	return time.Now()
+}
+
+// Version returns the version of Icinga DB as reported by running its binary with --version.
+func (i IcingaDb) Version() (utils.Version, error) {
+	// The Icinga DB process is the main process of its container, so this works independent of the binary location.
+	res, err := i.Exec(context.Background(), []string{"/proc/1/exe", "--version"}, nil)
+	if err != nil {
+		return utils.Version{}, err
+	}
+	if res.ExitCode != 0 {
+		return utils.Version{}, fmt.Errorf("icingadb --version exited with code %d: %s", res.ExitCode, res.Stderr)
+	}
+
+	// The first line looks like "Icinga DB version: v1.1.1".
+	for _, line := range strings.Split(string(res.Stdout), "\n") {
+		if _, v, ok := strings.Cut(line, "version:"); ok {
+			return utils.ParseVersion(v)
+		}
+	}
+
+	return utils.Version{}, fmt.Errorf("icingadb --version printed no version: %q", res.Stdout)
+}
+
+// RequireVersion skips the test if the version of Icinga DB does not satisfy the given constraint, for example
+// ">= 1.2". See utils.Version.Satisfies for the supported constraints.
+func (i IcingaDb) RequireVersion(t testing.TB, constraint string) {
+	t.Helper()
+	requireVersion(t, "Icinga DB", i.Version, constraint)
+}
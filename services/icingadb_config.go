@@ -0,0 +1,122 @@
+package services
+
+import (
+	"reflect"
+	"time"
+)
+
+// IcingaDbConfig is the configuration of Icinga DB as written to its config.yml. Fields that are nil or empty are
+// omitted, so that either the values set by icinga-testing (connection details, debug logging) or the defaults of
+// Icinga DB apply.
+//
+// Multiple configs are combined using Merge, so that only the fields set in a later config override earlier ones.
+//
+// https://icinga.com/docs/icinga-db/latest/doc/03-Configuration/
+type IcingaDbConfig struct {
+	Database  IcingaDbDatabaseConfig  `yaml:"database,omitempty"`
+	Redis     IcingaDbRedisConfig     `yaml:"redis,omitempty"`
+	Logging   IcingaDbLoggingConfig   `yaml:"logging,omitempty"`
+	Retention IcingaDbRetentionConfig `yaml:"retention,omitempty"`
+}
+
+// IcingaDbTlsConfig contains the TLS options for the database and Redis connections of Icinga DB.
+type IcingaDbTlsConfig struct {
+	Tls      *bool  `yaml:"tls,omitempty"`
+	Cert     string `yaml:"cert,omitempty"`
+	Key      string `yaml:"key,omitempty"`
+	Ca       string `yaml:"ca,omitempty"`
+	Insecure *bool  `yaml:"insecure,omitempty"`
+}
+
+// IcingaDbDatabaseConfig is the database section of the Icinga DB configuration.
+type IcingaDbDatabaseConfig struct {
+	Type              string `yaml:"type,omitempty"`
+	Host              string `yaml:"host,omitempty"`
+	Port              int    `yaml:"port,omitempty"`
+	Database          string `yaml:"database,omitempty"`
+	User              string `yaml:"user,omitempty"`
+	Password          string `yaml:"password,omitempty"`
+	IcingaDbTlsConfig `yaml:",inline"`
+	Options           IcingaDbDatabaseOptions `yaml:"options,omitempty"`
+}
+
+// IcingaDbDatabaseOptions are the options of the database section of the Icinga DB configuration.
+type IcingaDbDatabaseOptions struct {
+	MaxConnections              *int `yaml:"max_connections,omitempty"`
+	MaxConnectionsPerTable      *int `yaml:"max_connections_per_table,omitempty"`
+	MaxPlaceholdersPerStatement *int `yaml:"max_placeholders_per_statement,omitempty"`
+	MaxRowsPerTransaction       *int `yaml:"max_rows_per_transaction,omitempty"`
+	WsrepSyncWait               *int `yaml:"wsrep_sync_wait,omitempty"`
+}
+
+// IcingaDbRedisConfig is the redis section of the Icinga DB configuration.
+type IcingaDbRedisConfig struct {
+	Host              string `yaml:"host,omitempty"`
+	Port              int    `yaml:"port,omitempty"`
+	Username          string `yaml:"username,omitempty"`
+	Password          string `yaml:"password,omitempty"`
+	Database          *int   `yaml:"database,omitempty"`
+	IcingaDbTlsConfig `yaml:",inline"`
+	Options           IcingaDbRedisOptions `yaml:"options,omitempty"`
+}
+
+// IcingaDbRedisOptions are the options of the redis section of the Icinga DB configuration.
+type IcingaDbRedisOptions struct {
+	BlockTimeout        *time.Duration `yaml:"block_timeout,omitempty"`
+	HMGetCount          *int           `yaml:"hmget_count,omitempty"`
+	HScanCount          *int           `yaml:"hscan_count,omitempty"`
+	MaxHMGetConnections *int           `yaml:"max_hmget_connections,omitempty"`
+	Timeout             *time.Duration `yaml:"timeout,omitempty"`
+	XReadCount          *int           `yaml:"xread_count,omitempty"`
+}
+
+// IcingaDbLoggingConfig is the logging section of the Icinga DB configuration.
+type IcingaDbLoggingConfig struct {
+	Level    string         `yaml:"level,omitempty"`
+	Output   string         `yaml:"output,omitempty"`
+	Interval *time.Duration `yaml:"interval,omitempty"`
+
+	// Options sets the log level per component, for example "config-sync" or "history-sync".
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// IcingaDbRetentionConfig is the retention section of the Icinga DB configuration.
+type IcingaDbRetentionConfig struct {
+	HistoryDays *int           `yaml:"history-days,omitempty"`
+	SlaDays     *int           `yaml:"sla-days,omitempty"`
+	Interval    *time.Duration `yaml:"interval,omitempty"`
+	Count       *int           `yaml:"count,omitempty"`
+
+	// Options sets the number of days to keep per history category, for example "state" or "downtime".
+	Options map[string]int `yaml:"options,omitempty"`
+}
+
+// Merge overrides all fields of c that are set in other, i.e. not nil or empty. Maps are merged key by key.
+func (c *IcingaDbConfig) Merge(other IcingaDbConfig) {
+	mergeValue(reflect.ValueOf(c).Elem(), reflect.ValueOf(other))
+}
+
+// mergeValue merges src into dst, which must be of the same type, as described for IcingaDbConfig.Merge.
+func mergeValue(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+		}
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
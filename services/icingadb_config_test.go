@@ -0,0 +1,51 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIcingaDbConfigMerge(t *testing.T) {
+	interval := time.Second
+	days := 7
+	insecure := true
+
+	config := IcingaDbConfig{
+		Database: IcingaDbDatabaseConfig{Type: "mysql", Host: "db", Port: 3306},
+		Logging: IcingaDbLoggingConfig{
+			Level:    "debug",
+			Interval: &interval,
+			Options:  map[string]string{"config-sync": "debug"},
+		},
+	}
+	config.Merge(IcingaDbConfig{
+		Database: IcingaDbDatabaseConfig{
+			Host:              "other",
+			IcingaDbTlsConfig: IcingaDbTlsConfig{Insecure: &insecure},
+		},
+		Logging: IcingaDbLoggingConfig{
+			Level:   "info",
+			Options: map[string]string{"history-sync": "warn"},
+		},
+		Retention: IcingaDbRetentionConfig{HistoryDays: &days},
+	})
+
+	want := IcingaDbConfig{
+		Database: IcingaDbDatabaseConfig{
+			Type:              "mysql",
+			Host:              "other",
+			Port:              3306,
+			IcingaDbTlsConfig: IcingaDbTlsConfig{Insecure: &insecure},
+		},
+		Logging: IcingaDbLoggingConfig{
+			Level:    "info",
+			Interval: &interval,
+			Options:  map[string]string{"config-sync": "debug", "history-sync": "warn"},
+		},
+		Retention: IcingaDbRetentionConfig{HistoryDays: &days},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Merge() = %+v, want %+v", config, want)
+	}
+}
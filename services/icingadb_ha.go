@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IcingaDbHA is a group of Icinga DB instances using the same Redis server and database, so that only one of them is
+// responsible for writing to the database at any time.
+type IcingaDbHA []IcingaDb
+
+// Responsible returns the instance that is currently responsible. It fails if no instance is responsible.
+//
+// The responsible instance is determined from the icingadb_instance table: the row with responsible set and a recent
+// heartbeat. Its id is mapped to the instance using IcingaDb.InstanceId.
+func (h IcingaDbHA) Responsible(ctx context.Context) (IcingaDb, error) {
+	if len(h) == 0 {
+		return IcingaDb{}, errors.New("no icingadb instances")
+	}
+
+	rdb := h[0].RelationalDatabase()
+	db, err := sql.Open(rdb.Driver(), rdb.DSN())
+	if err != nil {
+		return IcingaDb{}, err
+	}
+	defer func() { _ = db.Close() }()
+
+	return h.responsible(ctx, db)
+}
+
+func (h IcingaDbHA) responsible(ctx context.Context, db *sql.DB) (IcingaDb, error) {
+	// Heartbeats are written using the clocks of the instances, so the earliest of their times is used.
+	now := time.Now()
+	for _, i := range h {
+		if t := i.now(); t.Before(now) {
+			now = t
+		}
+	}
+	minHeartbeat := now.Add(-icingaDbHeartbeatMaxAge).UnixMilli()
+
+	var ids [][]byte
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id FROM icingadb_instance WHERE responsible = 'y' AND heartbeat >= %d", minHeartbeat))
+	if err != nil {
+		return IcingaDb{}, fmt.Errorf("failed to query icingadb_instance: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			return IcingaDb{}, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return IcingaDb{}, err
+	}
+
+	if len(ids) == 0 {
+		return IcingaDb{}, errors.New("no icingadb instance is responsible")
+	} else if len(ids) > 1 {
+		return IcingaDb{}, fmt.Errorf("%d icingadb instances are responsible at the same time", len(ids))
+	}
+
+	for _, i := range h {
+		if id, err := i.InstanceId(); err == nil && bytes.Equal(id, ids[0]) {
+			return i, nil
+		}
+	}
+
+	return IcingaDb{}, fmt.Errorf("instance %x is responsible, but it is none of the %d instances", ids[0], len(h))
+}
+
+// WaitResponsible waits until an instance is responsible and returns it.
+func (h IcingaDbHA) WaitResponsible(ctx context.Context) (IcingaDb, error) {
+	return h.waitResponsible(ctx, func(IcingaDb) bool { return true })
+}
+
+// WaitTakeover waits until an instance other than previous is responsible and returns it. This is used after killing
+// or pausing the responsible instance to check that another one takes over within the deadline of ctx.
+//
+// Example usage:
+//
+//	active, err := ha.WaitResponsible(ctx)
+//	require.NoError(t, err)
+//	require.NoError(t, active.Pause())
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+//	defer cancel()
+//	_, err = ha.WaitTakeover(ctx, active)
+//	require.NoError(t, err)
+func (h IcingaDbHA) WaitTakeover(ctx context.Context, previous IcingaDb) (IcingaDb, error) {
+	return h.waitResponsible(ctx, func(i IcingaDb) bool {
+		return i.IcingaDbBase != previous.IcingaDbBase
+	})
+}
+
+// waitResponsible waits until an instance is responsible for which accept returns true.
+func (h IcingaDbHA) waitResponsible(ctx context.Context, accept func(IcingaDb) bool) (IcingaDb, error) {
+	if len(h) == 0 {
+		return IcingaDb{}, errors.New("no icingadb instances")
+	}
+
+	rdb := h[0].RelationalDatabase()
+	db, err := sql.Open(rdb.Driver(), rdb.DSN())
+	if err != nil {
+		return IcingaDb{}, err
+	}
+	defer func() { _ = db.Close() }()
+
+	interval := time.NewTicker(100 * time.Millisecond)
+	defer interval.Stop()
+
+	for {
+		var i IcingaDb
+		i, err = h.responsible(ctx, db)
+		if err == nil {
+			if accept(i) {
+				return i, nil
+			}
+			err = errors.New("the previously responsible instance is still responsible")
+		}
+
+		select {
+		case <-interval.C:
+		case <-ctx.Done():
+			return IcingaDb{}, fmt.Errorf("waiting for a responsible icingadb instance failed: %w", err)
+		}
+	}
+}
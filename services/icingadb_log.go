@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// icingaDbLogTimeLayout is the format of the timestamps written by Icinga DB (zapcore.ISO8601TimeEncoder).
+const icingaDbLogTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// icingaDbHaStarted is the debug message Icinga DB logs including its instance ID when its HA handling starts.
+const icingaDbHaStarted = "Starting HA"
+
+// IcingaDbLogEntry is a single log message written by Icinga DB.
+type IcingaDbLogEntry struct {
+	// Time is the time at which the message was logged.
+	Time time.Time
+
+	// Level is the log level in lower case, i.e. "debug", "info", "warn", "error" or "fatal". It is empty for output
+	// that is not a log message, for example the stack trace of a panic.
+	Level string
+
+	// Component is the name of the logger, for example "config-sync", "history-sync", "retention" or
+	// "high-availability".
+	Component string
+
+	// Message is the log message without its fields.
+	Message string
+
+	// Fields contains the structured fields of the message, if any.
+	Fields map[string]interface{}
+
+	// Raw is the line as written by Icinga DB.
+	Raw string
+}
+
+// ParseIcingaDbLogLine parses a line written by Icinga DB using either the console or the JSON encoding of zap. If the
+// line is not a log message, an entry with only Message and Raw set is returned together with false.
+func ParseIcingaDbLogLine(line string) (IcingaDbLogEntry, bool) {
+	raw := IcingaDbLogEntry{Message: line, Raw: line}
+
+	if strings.HasPrefix(line, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return raw, false
+		}
+
+		e := IcingaDbLogEntry{Fields: fields, Raw: line}
+		var ok bool
+		if e.Level, ok = fields["level"].(string); !ok {
+			return raw, false
+		}
+		e.Level = strings.ToLower(e.Level)
+		e.Component, _ = fields["logger"].(string)
+		e.Message, _ = fields["msg"].(string)
+		if ts, ok := fields["ts"].(string); ok {
+			e.Time, _ = time.Parse(icingaDbLogTimeLayout, ts)
+		}
+		for _, key := range []string{"ts", "level", "logger", "msg"} {
+			delete(fields, key)
+		}
+		return e, true
+	}
+
+	// The console encoding separates the timestamp, level, logger name, message and fields as JSON object by tabs.
+	parts := strings.SplitN(line, "\t", 4)
+	if len(parts) != 4 {
+		return raw, false
+	}
+	ts, err := time.Parse(icingaDbLogTimeLayout, parts[0])
+	if err != nil {
+		return raw, false
+	}
+
+	e := IcingaDbLogEntry{
+		Time:      ts,
+		Level:     strings.ToLower(parts[1]),
+		Component: parts[2],
+		Message:   parts[3],
+		Raw:       line,
+	}
+	if i := strings.LastIndex(e.Message, "\t{"); i >= 0 {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(e.Message[i+1:]), &fields); err == nil {
+			e.Message = e.Message[:i]
+			e.Fields = fields
+		}
+	}
+
+	return e, true
+}
+
+// Logs returns all messages the instance has logged so far, see ParseIcingaDbLogLine.
+func (i IcingaDb) Logs() []IcingaDbLogEntry {
+	logs, _ := i.LogsSince(0)
+	return logs
+}
+
+// InstanceId returns the ID Icinga DB generated for the instance on startup, i.e. the id of its row in the
+// icingadb_instance table. It is taken from the debug message logged by the high-availability component on startup,
+// so InstanceId fails until that message was logged or if debug messages of that component are disabled.
+func (i IcingaDb) InstanceId() ([]byte, error) {
+	var id string
+	for _, e := range i.Logs() {
+		if e.Component == "high-availability" && e.Message == icingaDbHaStarted {
+			id, _ = e.Fields["instance_id"].(string)
+		}
+	}
+	if id == "" {
+		return nil, errors.New("icingadb did not log its instance id yet")
+	}
+
+	return hex.DecodeString(id)
+}
+
+// WaitForLog waits until the instance logs a message matching msg from the given component and returns it. If
+// component is empty, messages from all components are considered. Messages logged before WaitForLog was called are
+// considered as well. WaitForLog fails immediately if the instance exits.
+//
+// Example usage:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	_, err := i.WaitForLog(ctx, "config-sync", regexp.MustCompile(`^Finished config sync`))
+//	require.NoError(t, err)
+func (i IcingaDb) WaitForLog(ctx context.Context, component string, msg *regexp.Regexp) (IcingaDbLogEntry, error) {
+	interval := time.NewTicker(100 * time.Millisecond)
+	defer interval.Stop()
+
+	// Only messages logged since the last check are considered, as the output of long-running instances can get large.
+	var logs []IcingaDbLogEntry
+	checked := 0
+	for {
+		logs, checked = i.LogsSince(checked)
+		for _, e := range logs {
+			if (component == "" || e.Component == component) && msg.MatchString(e.Message) {
+				return e, nil
+			}
+		}
+
+		if status, exited := i.ExitStatus(); exited {
+			return IcingaDbLogEntry{}, fmt.Errorf("icingadb exited with code %d before logging %q%s",
+				status.ExitCode, msg, formatOutput(i.Output(), 20))
+		}
+
+		select {
+		case <-interval.C:
+		case <-ctx.Done():
+			return IcingaDbLogEntry{}, fmt.Errorf("icingadb did not log %q: %w", msg, ctx.Err())
+		}
+	}
+}
+
+// LogErrors returns all messages with level error or fatal that do not match one of the patterns set using
+// WithIcingaDbAllowedLogErrors.
+func (i IcingaDb) LogErrors() []IcingaDbLogEntry {
+	if i.allowAllLogErrors {
+		return nil
+	}
+
+	var errs []IcingaDbLogEntry
+entries:
+	for _, e := range i.Logs() {
+		if e.Level != "error" && e.Level != "fatal" {
+			continue
+		}
+		for _, allowed := range i.allowedLogErrors {
+			if allowed.MatchString(e.Message) {
+				continue entries
+			}
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// AssertNoLogErrors marks t as failed if the instance logged any messages reported by LogErrors. This is done by
+// default for instances created by IT.IcingaDbInstanceT when the test finishes.
+func (i IcingaDb) AssertNoLogErrors(t testing.TB) {
+	t.Helper()
+
+	if errs := i.LogErrors(); len(errs) > 0 {
+		lines := make([]string, 0, len(errs))
+		for _, e := range errs {
+			lines = append(lines, e.Raw)
+		}
+		t.Errorf("icingadb logged %d errors:\n%s", len(errs), strings.Join(lines, "\n"))
+	}
+}
+
+// WithIcingaDbAllowedLogErrors allows log messages with level error or fatal matching one of the given patterns, so
+// that they are not reported by IcingaDb.LogErrors. Without any patterns, all errors are allowed. This is useful for
+// tests that provoke errors on purpose, for example by stopping the database.
+func WithIcingaDbAllowedLogErrors(patterns ...*regexp.Regexp) func(*IcingaDb) {
+	return func(db *IcingaDb) {
+		if len(patterns) == 0 {
+			db.allowAllLogErrors = true
+		}
+		db.allowedLogErrors = append(db.allowedLogErrors, patterns...)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIcingaDbLogLine(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123000000, time.UTC)
+
+	tests := []struct {
+		line   string
+		want   IcingaDbLogEntry
+		wantOk bool
+	}{{
+		line: "2024-03-01T12:30:45.123Z\tINFO\tconfig-sync\tFinished config sync in 1.5s",
+		want: IcingaDbLogEntry{
+			Time:      ts,
+			Level:     "info",
+			Component: "config-sync",
+			Message:   "Finished config sync in 1.5s",
+		},
+		wantOk: true,
+	}, {
+		line: "2024-03-01T12:30:45.123Z\tERROR\thigh-availability\t" +
+			"Can't update or insert instance\t{\"error\": \"timeout\"}",
+		want: IcingaDbLogEntry{
+			Time:      ts,
+			Level:     "error",
+			Component: "high-availability",
+			Message:   "Can't update or insert instance",
+			Fields:    map[string]interface{}{"error": "timeout"},
+		},
+		wantOk: true,
+	}, {
+		line: `{"level":"warn","ts":"2024-03-01T12:30:45.123Z","logger":"retention","msg":"Skipping","days":7}`,
+		want: IcingaDbLogEntry{
+			Time:      ts,
+			Level:     "warn",
+			Component: "retention",
+			Message:   "Skipping",
+			Fields:    map[string]interface{}{"days": float64(7)},
+		},
+		wantOk: true,
+	}, {
+		line:   "panic: runtime error: invalid memory address or nil pointer dereference",
+		want:   IcingaDbLogEntry{Message: "panic: runtime error: invalid memory address or nil pointer dereference"},
+		wantOk: false,
+	}}
+
+	for _, test := range tests {
+		test.want.Raw = test.line
+		got, ok := ParseIcingaDbLogLine(test.line)
+		if ok != test.wantOk || !got.Time.Equal(test.want.Time) {
+			t.Errorf("ParseIcingaDbLogLine(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.wantOk)
+			continue
+		}
+		got.Time = test.want.Time
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseIcingaDbLogLine(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+// fakeIcingaDbLogs implements the LogsSince function of IcingaDbBase by parsing the given lines.
+type fakeIcingaDbLogs struct {
+	IcingaDbBase
+	lines []string
+}
+
+func (f fakeIcingaDbLogs) LogsSince(n int) ([]IcingaDbLogEntry, int) {
+	var logs []IcingaDbLogEntry
+	for _, line := range f.lines[n:] {
+		e, _ := ParseIcingaDbLogLine(line)
+		logs = append(logs, e)
+	}
+	return logs, len(f.lines)
+}
+
+func TestIcingaDbInstanceId(t *testing.T) {
+	i := IcingaDb{IcingaDbBase: fakeIcingaDbLogs{lines: []string{
+		"2024-03-01T12:30:45.123Z\tINFO\tmain\tStarting Icinga DB daemon (1.2.0)",
+	}}}
+	if id, err := i.InstanceId(); err == nil {
+		t.Errorf("InstanceId() = %x, <nil>, want an error", id)
+	}
+
+	i.IcingaDbBase = fakeIcingaDbLogs{lines: []string{
+		"2024-03-01T12:30:45.123Z\tINFO\tmain\tStarting Icinga DB daemon (1.2.0)",
+		"2024-03-01T12:30:45.124Z\tDEBUG\thigh-availability\tStarting HA\t{\"instance_id\": \"0123456789abcdef\"}",
+	}}
+	want := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if id, err := i.InstanceId(); err != nil || !bytes.Equal(id, want) {
+		t.Errorf("InstanceId() = %x, %v, want 0123456789abcdef, <nil>", id, err)
+	}
+}
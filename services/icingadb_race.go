@@ -0,0 +1,50 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// DataRaces returns the data race reports printed by the instance so far, each consisting of all lines of the report
+// joined by newlines. Reports are only printed by binaries built using "go build -race".
+func (i IcingaDb) DataRaces() []string {
+	return dataRaces(i.Output())
+}
+
+// AssertNoDataRaces marks t as failed if the instance reported any data races. This is done by default for instances
+// created by IT.IcingaDbInstanceT when the test finishes.
+func (i IcingaDb) AssertNoDataRaces(t testing.TB) {
+	t.Helper()
+
+	if races := i.DataRaces(); len(races) > 0 {
+		t.Errorf("icingadb reported %d data races:\n%s", len(races), strings.Join(races, "\n\n"))
+	}
+}
+
+// dataRaces extracts the data race reports from the output of a Go program. The race detector prints each report
+// between two lines of equals signs, starting with "WARNING: DATA RACE".
+func dataRaces(output []string) []string {
+	var races []string
+	var report []string
+	inReport := false
+
+	for _, line := range output {
+		switch {
+		case strings.HasPrefix(line, "WARNING: DATA RACE"):
+			inReport = true
+			report = []string{line}
+		case inReport && strings.HasPrefix(line, "=================="):
+			races = append(races, strings.Join(report, "\n"))
+			inReport = false
+		case inReport:
+			report = append(report, line)
+		}
+	}
+
+	// The program may have been stopped while printing a report.
+	if inReport {
+		races = append(races, strings.Join(report, "\n"))
+	}
+
+	return races
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDataRaces(t *testing.T) {
+	output := []string{
+		"2024-03-01T12:30:45.123Z\tINFO\ticingadb\tStarting Icinga DB",
+		"==================",
+		"WARNING: DATA RACE",
+		"Write at 0x00c000123456 by goroutine 7:",
+		"  main.main.func1()",
+		"==================",
+		"2024-03-01T12:30:46.123Z\tINFO\tconfig-sync\tFinished config sync in 1s",
+		"==================",
+		"WARNING: DATA RACE",
+		"Read at 0x00c000654321 by goroutine 9:",
+	}
+
+	want := []string{
+		"WARNING: DATA RACE\nWrite at 0x00c000123456 by goroutine 7:\n  main.main.func1()",
+		"WARNING: DATA RACE\nRead at 0x00c000654321 by goroutine 9:",
+	}
+	if got := dataRaces(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("dataRaces() = %q, want %q", got, want)
+	}
+
+	if got := dataRaces(output[:1]); got != nil {
+		t.Errorf("dataRaces() = %q, want nil", got)
+	}
+}
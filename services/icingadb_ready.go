@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icingaDbHeartbeatMaxAge is the maximum age of heartbeats considered by WaitReady. Icinga DB writes a heartbeat
+// every second, so older heartbeats are from an instance that is not running anymore.
+const icingaDbHeartbeatMaxAge = 5 * time.Second
+
+// icingaDbConfigSyncFinished matches the message Icinga DB logs once the initial config sync finished.
+var icingaDbConfigSyncFinished = regexp.MustCompile(`^Finished config sync`)
+
+// IcingaDbReadyOption configures IcingaDb.WaitReady.
+type IcingaDbReadyOption func(*icingaDbReady)
+
+type icingaDbReady struct {
+	configSync bool
+
+	// configSynced is set once the config sync finished. Until then, logsChecked is the number of log messages that
+	// were already checked for the corresponding one.
+	configSynced bool
+	logsChecked  int
+}
+
+// WithIcingaDbConfigSync makes IcingaDb.WaitReady additionally wait until the initial config sync finished.
+func WithIcingaDbConfigSync() IcingaDbReadyOption {
+	return func(r *icingaDbReady) {
+		r.configSync = true
+	}
+}
+
+// WaitReady waits until the Icinga DB instance is up and running, i.e. its process is running, there are recent
+// heartbeats in the icingadb:telemetry:heartbeat Redis stream and the row of the instance in the icingadb_instance
+// table, see InstanceId, has a recent heartbeat. As the Redis stream is shared by all instances using the same Redis
+// server, only the latter is specific to this instance.
+// If the instance exits instead, WaitReady fails immediately, including the last lines of its output in the error.
+//
+// Example usage:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	require.NoError(t, i.WaitReady(ctx, services.WithIcingaDbConfigSync()))
+func (i IcingaDb) WaitReady(ctx context.Context, options ...IcingaDbReadyOption) error {
+	r := &icingaDbReady{}
+	for _, option := range options {
+		option(r)
+	}
+
+	rc := RedisServer{RedisServerBase: i.Redis()}.Open()
+	defer func() { _ = rc.Close() }()
+
+	db, err := sql.Open(i.RelationalDatabase().Driver(), i.RelationalDatabase().DSN())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	interval := time.NewTicker(100 * time.Millisecond)
+	defer interval.Stop()
+
+	for {
+		if status, exited := i.ExitStatus(); exited {
+			return fmt.Errorf("icingadb exited with code %d%s", status.ExitCode, formatOutput(i.Output(), 20))
+		}
+
+		err = i.checkReady(ctx, r, rc, db)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-interval.C:
+		case <-ctx.Done():
+			return fmt.Errorf("icingadb did not become ready: %w%s", err, formatOutput(i.Output(), 20))
+		}
+	}
+}
+
+// checkReady returns nil if the instance is ready or an error describing what is missing otherwise.
+func (i IcingaDb) checkReady(ctx context.Context, r *icingaDbReady, rc *redis.Client, db *sql.DB) error {
+	minHeartbeat := time.Now().Add(-icingaDbHeartbeatMaxAge)
+	// The heartbeat in the icingadb_instance table is written using the clock of the instance.
+	minInstanceHeartbeat := i.now().Add(-icingaDbHeartbeatMaxAge)
+
+	messages, err := rc.XRevRangeN(ctx, "icingadb:telemetry:heartbeat", "+", "-", 1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read heartbeat from redis: %w", err)
+	}
+	if len(messages) == 0 {
+		return errors.New("no heartbeat in redis yet")
+	}
+	// Stream entry IDs start with the time in milliseconds at which the entry was added.
+	ms, err := strconv.ParseInt(strings.SplitN(messages[0].ID, "-", 2)[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid heartbeat stream entry ID %q: %w", messages[0].ID, err)
+	}
+	if time.UnixMilli(ms).Before(minHeartbeat) {
+		return errors.New("no recent heartbeat in redis")
+	}
+
+	instanceId, err := i.InstanceId()
+	if err != nil {
+		return err
+	}
+
+	// Other instances may share the database, so only the row of this instance is considered.
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id FROM icingadb_instance WHERE heartbeat >= %d", minInstanceHeartbeat.UnixMilli()))
+	if err != nil {
+		return fmt.Errorf("failed to query icingadb_instance: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var heartbeat bool
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		heartbeat = heartbeat || bytes.Equal(id, instanceId)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !heartbeat {
+		return fmt.Errorf("no recent heartbeat of instance %x in icingadb_instance table", instanceId)
+	}
+
+	if r.configSync && !r.configSynced {
+		var logs []IcingaDbLogEntry
+		logs, r.logsChecked = i.LogsSince(r.logsChecked)
+		for _, e := range logs {
+			if e.Component == "config-sync" && icingaDbConfigSyncFinished.MatchString(e.Message) {
+				r.configSynced = true
+				break
+			}
+		}
+		if !r.configSynced {
+			return errors.New("initial config sync did not finish yet")
+		}
+	}
+
+	return nil
+}
+
+// formatOutput returns the last n lines of output for appending them to an error message.
+func formatOutput(output []string, n int) string {
+	if len(output) > n {
+		output = output[len(output)-n:]
+	}
+	if len(output) == 0 {
+		return " (no output)"
+	}
+	return fmt.Sprintf(", last %d lines of output:\n%s", len(output), strings.Join(output, "\n"))
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// icingaDbSchemaVersionInsert matches the statement by which Icinga DB schema files record their schema version.
+var icingaDbSchemaVersionInsert = regexp.MustCompile(
+	`(?i)INSERT\s+INTO\s+icingadb_schema\s*\(\s*version\s*,[^)]*\)\s*VALUES\s*\(\s*(\d+)`)
+
+// icingaDbSchemaImporter is the part of RelationalDatabase needed for importing the Icinga DB schema and upgrades.
+type icingaDbSchemaImporter interface {
+	IcingaDbType() string
+	Driver() string
+	DSN() string
+	ImportSchema(schema string) error
+}
+
+// icingaDbUpgrade is an upgrade script within the schema/<type>/upgrades directory of an Icinga DB source checkout.
+type icingaDbUpgrade struct {
+	file    string
+	version utils.Version
+	schema  string
+
+	// schemaVersion is the version the script writes to the icingadb_schema table, or 0 if it does not.
+	schemaVersion int
+}
+
+// importIcingaDbSchemaVersion implements RelationalDatabase.ImportIcingaDbSchemaVersion.
+func importIcingaDbSchemaVersion(rdb icingaDbSchemaImporter, dir string, from string) error {
+	ref := "v" + strings.TrimPrefix(from, "v")
+	file := path.Join("schema", rdb.IcingaDbType(), "schema.sql")
+
+	// Check the requirements separately, as the error of git show does not tell what is missing.
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--git-dir").Run(); err != nil {
+		return fmt.Errorf("%q must be a git clone of Icinga DB to read the schema of older releases: %w", dir, err)
+	}
+	err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "refs/tags/"+ref+"^{commit}").Run()
+	if err != nil {
+		return fmt.Errorf("the git clone %q has no tag %s, fetch the tags of Icinga DB using git fetch --tags: %w",
+			dir, ref, err)
+	}
+
+	cmd := exec.Command("git", "-C", dir, "show", ref+":"+file)
+	schema, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to read %s of icingadb %s from %q: %w: %s", file, ref, dir, err, exitErr.Stderr)
+		}
+		return err
+	}
+
+	if err := rdb.ImportSchema(string(schema)); err != nil {
+		return fmt.Errorf("failed to import schema of icingadb %s: %w", ref, err)
+	}
+
+	return nil
+}
+
+// applyIcingaDbUpgrades implements RelationalDatabase.ApplyUpgrades.
+func applyIcingaDbUpgrades(rdb icingaDbSchemaImporter, dir string, to string) error {
+	toVersion, err := utils.ParseVersion(to)
+	if err != nil {
+		return err
+	}
+
+	upgrades, err := readIcingaDbUpgrades(filepath.Join(dir, "schema", rdb.IcingaDbType(), "upgrades"))
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(rdb.Driver(), rdb.DSN())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	current, err := icingaDbSchemaVersion(context.Background(), db, rdb.Driver())
+	if err != nil {
+		return err
+	}
+
+	for _, u := range upgrades {
+		if u.version.Compare(toVersion) > 0 {
+			break
+		}
+		// Scripts not recording a schema version predate the icingadb_schema table.
+		if (u.schemaVersion == 0 && current > 0) || (u.schemaVersion > 0 && u.schemaVersion <= current) {
+			continue
+		}
+
+		if err := rdb.ImportSchema(u.schema); err != nil {
+			return fmt.Errorf("failed to apply upgrade %s: %w", u.file, err)
+		}
+		if u.schemaVersion > 0 {
+			current = u.schemaVersion
+		}
+	}
+
+	return nil
+}
+
+// readIcingaDbUpgrades reads all upgrade scripts from dir, ordered by the release they upgrade to. Release candidates
+// of a release are ordered before the release itself.
+func readIcingaDbUpgrades(dir string) ([]icingaDbUpgrade, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	upgrades := make([]icingaDbUpgrade, 0, len(files))
+	for _, file := range files {
+		version, err := utils.ParseVersion(strings.TrimSuffix(filepath.Base(file), ".sql"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid upgrade file name %q: %w", file, err)
+		}
+
+		schema, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		u := icingaDbUpgrade{file: file, version: version, schema: string(schema)}
+		if match := icingaDbSchemaVersionInsert.FindStringSubmatch(u.schema); match != nil {
+			u.schemaVersion, err = strconv.Atoi(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema version in %q: %w", file, err)
+			}
+		}
+
+		upgrades = append(upgrades, u)
+	}
+
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		if c := upgrades[i].version.Compare(upgrades[j].version); c != 0 {
+			return c < 0
+		}
+		return strings.Contains(upgrades[i].version.Raw, "-") && !strings.Contains(upgrades[j].version.Raw, "-")
+	})
+
+	return upgrades, nil
+}
+
+// icingaDbSchemaVersion returns the latest version recorded in the icingadb_schema table, or 0 if there is no such
+// table as in schemas older than Icinga DB 1.0.0.
+func icingaDbSchemaVersion(ctx context.Context, db *sql.DB, driver string) (int, error) {
+	schema := "DATABASE()"
+	if driver != "mysql" {
+		schema = "current_schema()"
+	}
+
+	var tables int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables"+
+		" WHERE table_schema = "+schema+" AND table_name = 'icingadb_schema'").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM icingadb_schema").Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
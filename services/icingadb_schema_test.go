@@ -0,0 +1,83 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadIcingaDbUpgrades(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1.0.0.sql": "ALTER TABLE host ADD COLUMN foo int;\n" +
+			"INSERT INTO icingadb_schema (version, timestamp) VALUES (2, 0);",
+		"1.0.0-rc2.sql": "ALTER TABLE host ADD COLUMN bar int;",
+		"1.1.1.sql":     "INSERT INTO icingadb_schema (version, TIMESTAMP)\n  VALUES (4, UNIX_TIMESTAMP() * 1000);",
+		"1.1.0.sql":     "INSERT INTO icingadb_schema (version, timestamp) VALUES (3, 0);",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upgrades, err := readIcingaDbUpgrades(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var gotVersions []int
+	for _, u := range upgrades {
+		got = append(got, filepath.Base(u.file))
+		gotVersions = append(gotVersions, u.schemaVersion)
+	}
+
+	want := []string{"1.0.0-rc2.sql", "1.0.0.sql", "1.1.0.sql", "1.1.1.sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readIcingaDbUpgrades() = %v, want %v", got, want)
+	}
+	wantVersions := []int{0, 2, 3, 4}
+	if !reflect.DeepEqual(gotVersions, wantVersions) {
+		t.Errorf("readIcingaDbUpgrades() schema versions = %v, want %v", gotVersions, wantVersions)
+	}
+}
+
+// fakeIcingaDbSchemaImporter fails the test if anything is imported.
+type fakeIcingaDbSchemaImporter struct {
+	icingaDbSchemaImporter
+	t *testing.T
+}
+
+func (f fakeIcingaDbSchemaImporter) IcingaDbType() string {
+	return "mysql"
+}
+
+func (f fakeIcingaDbSchemaImporter) ImportSchema(string) error {
+	f.t.Error("ImportSchema() called, want no import")
+	return nil
+}
+
+func TestImportIcingaDbSchemaVersionRequirements(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	rdb := fakeIcingaDbSchemaImporter{t: t}
+	err := importIcingaDbSchemaVersion(rdb, dir, "1.1.0")
+	if err == nil || !strings.Contains(err.Error(), "git clone") {
+		t.Errorf("importIcingaDbSchemaVersion() = %v, want an error about the missing git clone", err)
+	}
+
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	err = importIcingaDbSchemaVersion(rdb, dir, "1.1.0")
+	if err == nil || !strings.Contains(err.Error(), "no tag v1.1.0") {
+		t.Errorf("importIcingaDbSchemaVersion() = %v, want an error about the missing tag", err)
+	}
+}
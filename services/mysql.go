@@ -1,10 +1,16 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-testing/utils"
+	"io"
 	"os"
+	"strings"
+	"testing"
+	"time"
 )
 
 type MysqlDatabaseBase interface {
@@ -23,6 +29,22 @@ type MysqlDatabaseBase interface {
 	// Database returns the name of the database on the MySQL server.
 	Database() string
 
+	// Exec runs a command in the container of the MySQL server hosting the database and returns its output and exit
+	// code. If stdin is not nil, it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
+	// ExitStatus returns the exit status of the MySQL server hosting the database and true if it exited, or false if
+	// it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the MySQL server hosting the database exited.
+	Exited() <-chan struct{}
+
+	// Stop stops the MySQL server hosting the database by sending SIGTERM and waits up to timeout for it to exit before
+	// killing it. As the server is shared with other databases, this should only be used by tests that check its
+	// shutdown and that do not use any other databases afterwards.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup removes the MySQL database.
 	Cleanup()
 }
@@ -50,6 +72,40 @@ func (m MysqlDatabase) Open() (*sql.DB, error) {
 	return sql.Open(m.Driver(), m.DSN())
 }
 
+// Version returns the version of the MySQL or MariaDB server as returned by VERSION(). Use IsMariaDb to tell them
+// apart as their version numbers are not comparable.
+func (m MysqlDatabase) Version() (utils.Version, error) {
+	db, err := m.Open()
+	if err != nil {
+		return utils.Version{}, err
+	}
+	defer func() { _ = db.Close() }()
+
+	var version string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return utils.Version{}, err
+	}
+
+	return utils.ParseVersion(version)
+}
+
+// IsMariaDb returns whether the server is a MariaDB server.
+func (m MysqlDatabase) IsMariaDb() (bool, error) {
+	version, err := m.Version()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(version.Raw, "MariaDB"), nil
+}
+
+// RequireVersion skips the test if the version of the MySQL or MariaDB server does not satisfy the given constraint,
+// for example ">= 8.0". See utils.Version.Satisfies for the supported constraints.
+func (m MysqlDatabase) RequireVersion(t testing.TB, constraint string) {
+	t.Helper()
+	requireVersion(t, "MySQL", m.Version, constraint)
+}
+
 func (m MysqlDatabase) ImportIcingaDbSchema() {
 	key := "ICINGA_TESTING_ICINGADB_SCHEMA_MYSQL"
 	schemaFile, ok := os.LookupEnv(key)
@@ -62,14 +118,35 @@ func (m MysqlDatabase) ImportIcingaDbSchema() {
 		panic(fmt.Errorf("failed to read icingadb schema file %q: %w", schemaFile, err))
 	}
 
-	db, err := MysqlDatabase{m}.Open()
-	if err != nil {
+	if err := m.ImportSchema(string(schema)); err != nil {
 		panic(err)
 	}
+}
+
+// ImportIcingaDbSchemaVersion imports the Icinga DB schema of an older release, see
+// RelationalDatabase.ImportIcingaDbSchemaVersion.
+func (m MysqlDatabase) ImportIcingaDbSchemaVersion(dir string, from string) error {
+	return importIcingaDbSchemaVersion(m, dir, from)
+}
+
+// ApplyUpgrades applies the Icinga DB schema upgrades up to release to, see RelationalDatabase.ApplyUpgrades.
+func (m MysqlDatabase) ApplyUpgrades(dir string, to string) error {
+	return applyIcingaDbUpgrades(m, dir, to)
+}
+
+// ImportSchema executes all statements of an SQL schema file, for example the Icinga DB or IDO schema.
+func (m MysqlDatabase) ImportSchema(schema string) error {
+	db, err := m.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
 
-	for _, stmt := range database.MysqlSplitStatements(string(schema)) {
+	for _, stmt := range database.MysqlSplitStatements(schema) {
 		if _, err := db.Exec(stmt); err != nil {
-			panic(err)
+			return fmt.Errorf("failed to execute %q: %w", stmt, err)
 		}
 	}
+
+	return nil
 }
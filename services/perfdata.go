@@ -0,0 +1,169 @@
+package services
+
+import (
+	"strings"
+	"time"
+)
+
+// GraphiteMetric is a single metric received using the Graphite plaintext protocol.
+type GraphiteMetric struct {
+	Path      string
+	Value     float64
+	Timestamp time.Time
+}
+
+// InfluxdbPoint is a single point received using the InfluxDB line protocol.
+type InfluxdbPoint struct {
+	// Database is the database (InfluxDB 1.x) or bucket (InfluxDB 2.x) the point was written to.
+	Database    string
+	Measurement string
+	Tags        map[string]string
+	// Fields contains the field values, which are of type float64, int64, string or bool.
+	Fields    map[string]interface{}
+	Timestamp time.Time
+}
+
+// OpenTsdbMetric is a single metric received using the OpenTSDB telnet protocol.
+type OpenTsdbMetric struct {
+	Metric    string
+	Value     float64
+	Timestamp time.Time
+	Tags      map[string]string
+}
+
+// GelfMessage is a single message received using the GELF protocol. It contains the decoded JSON object.
+type GelfMessage map[string]interface{}
+
+// ElasticsearchDocument is a single document received using the Elasticsearch bulk API.
+type ElasticsearchDocument struct {
+	Index  string
+	Source map[string]interface{}
+}
+
+type GraphiteReceiverBase interface {
+	// Host returns the host on which the receiver can be reached from the containers.
+	Host() string
+
+	// Port returns the port on which the receiver can be reached from the containers.
+	Port() string
+
+	// Metrics returns all metrics received so far.
+	Metrics() []GraphiteMetric
+
+	// Cleanup stops the receiver.
+	Cleanup()
+}
+
+// GraphiteReceiver wraps the GraphiteReceiverBase interface and adds some helper functions.
+type GraphiteReceiver struct {
+	GraphiteReceiverBase
+}
+
+// MetricsWithPrefix returns all metrics received so far whose path starts with the given prefix.
+func (g GraphiteReceiver) MetricsWithPrefix(prefix string) []GraphiteMetric {
+	var result []GraphiteMetric
+	for _, m := range g.Metrics() {
+		if strings.HasPrefix(m.Path, prefix) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+type InfluxdbReceiverBase interface {
+	// Host returns the host on which the receiver can be reached from the containers.
+	Host() string
+
+	// Port returns the port on which the receiver can be reached from the containers.
+	Port() string
+
+	// Points returns all points received so far, both from the InfluxDB 1.x and 2.x write APIs.
+	Points() []InfluxdbPoint
+
+	// Cleanup stops the receiver.
+	Cleanup()
+}
+
+// InfluxdbReceiver wraps the InfluxdbReceiverBase interface and adds some helper functions.
+type InfluxdbReceiver struct {
+	InfluxdbReceiverBase
+}
+
+// PointsForMeasurement returns all points received so far for the given measurement.
+func (i InfluxdbReceiver) PointsForMeasurement(measurement string) []InfluxdbPoint {
+	var result []InfluxdbPoint
+	for _, p := range i.Points() {
+		if p.Measurement == measurement {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+type OpenTsdbReceiverBase interface {
+	// Host returns the host on which the receiver can be reached from the containers.
+	Host() string
+
+	// Port returns the port on which the receiver can be reached from the containers.
+	Port() string
+
+	// Metrics returns all metrics received so far.
+	Metrics() []OpenTsdbMetric
+
+	// Cleanup stops the receiver.
+	Cleanup()
+}
+
+// OpenTsdbReceiver wraps the OpenTsdbReceiverBase interface and adds some helper functions.
+type OpenTsdbReceiver struct {
+	OpenTsdbReceiverBase
+}
+
+// MetricsByName returns all metrics received so far with the given metric name.
+func (o OpenTsdbReceiver) MetricsByName(metric string) []OpenTsdbMetric {
+	var result []OpenTsdbMetric
+	for _, m := range o.Metrics() {
+		if m.Metric == metric {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+type GelfReceiverBase interface {
+	// Host returns the host on which the receiver can be reached from the containers.
+	Host() string
+
+	// Port returns the port on which the receiver can be reached from the containers.
+	Port() string
+
+	// Messages returns all messages received so far.
+	Messages() []GelfMessage
+
+	// Cleanup stops the receiver.
+	Cleanup()
+}
+
+// GelfReceiver wraps the GelfReceiverBase interface.
+type GelfReceiver struct {
+	GelfReceiverBase
+}
+
+type ElasticsearchReceiverBase interface {
+	// Host returns the host on which the receiver can be reached from the containers.
+	Host() string
+
+	// Port returns the port on which the receiver can be reached from the containers.
+	Port() string
+
+	// Documents returns all documents received so far.
+	Documents() []ElasticsearchDocument
+
+	// Cleanup stops the receiver.
+	Cleanup()
+}
+
+// ElasticsearchReceiver wraps the ElasticsearchReceiverBase interface.
+type ElasticsearchReceiver struct {
+	ElasticsearchReceiverBase
+}
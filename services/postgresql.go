@@ -1,11 +1,16 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"io"
 	"net"
 	"net/url"
 	"os"
+	"testing"
+	"time"
 )
 
 type PostgresqlDatabaseBase interface {
@@ -24,6 +29,22 @@ type PostgresqlDatabaseBase interface {
 	// Database returns the name of the database on the PostgreSQL server.
 	Database() string
 
+	// Exec runs a command in the container of the PostgreSQL server hosting the database and returns its output and
+	// exit code. If stdin is not nil, it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
+	// ExitStatus returns the exit status of the PostgreSQL server hosting the database and true if it exited, or false
+	// if it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the PostgreSQL server hosting the database exited.
+	Exited() <-chan struct{}
+
+	// Stop stops the PostgreSQL server hosting the database by sending SIGTERM and waits up to timeout for it to exit
+	// before killing it. As the server is shared with other databases, this should only be used by tests that check
+	// its shutdown and that do not use any other databases afterwards.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup removes the PostgreSQL database.
 	Cleanup()
 }
@@ -59,6 +80,29 @@ func (p PostgresqlDatabase) Open() (*sql.DB, error) {
 	return sql.Open(p.Driver(), p.DSN())
 }
 
+// Version returns the version of the PostgreSQL server as returned by SHOW server_version.
+func (p PostgresqlDatabase) Version() (utils.Version, error) {
+	db, err := p.Open()
+	if err != nil {
+		return utils.Version{}, err
+	}
+	defer func() { _ = db.Close() }()
+
+	var version string
+	if err := db.QueryRow("SHOW server_version").Scan(&version); err != nil {
+		return utils.Version{}, err
+	}
+
+	return utils.ParseVersion(version)
+}
+
+// RequireVersion skips the test if the version of the PostgreSQL server does not satisfy the given constraint, for
+// example ">= 12". See utils.Version.Satisfies for the supported constraints.
+func (p PostgresqlDatabase) RequireVersion(t testing.TB, constraint string) {
+	t.Helper()
+	requireVersion(t, "PostgreSQL", p.Version, constraint)
+}
+
 func (p PostgresqlDatabase) ImportIcingaDbSchema() {
 	key := "ICINGA_TESTING_ICINGADB_SCHEMA_PGSQL"
 	schemaFile, ok := os.LookupEnv(key)
@@ -71,11 +115,30 @@ func (p PostgresqlDatabase) ImportIcingaDbSchema() {
 		panic(fmt.Errorf("failed to read icingadb schema file %q: %w", schemaFile, err))
 	}
 
-	db, err := PostgresqlDatabase{PostgresqlDatabaseBase: p}.Open()
-	if err != nil {
+	if err := p.ImportSchema(string(schema)); err != nil {
 		panic(err)
 	}
-	if _, err := db.Exec(string(schema)); err != nil {
-		panic(err)
+}
+
+// ImportIcingaDbSchemaVersion imports the Icinga DB schema of an older release, see
+// RelationalDatabase.ImportIcingaDbSchemaVersion.
+func (p PostgresqlDatabase) ImportIcingaDbSchemaVersion(dir string, from string) error {
+	return importIcingaDbSchemaVersion(p, dir, from)
+}
+
+// ApplyUpgrades applies the Icinga DB schema upgrades up to release to, see RelationalDatabase.ApplyUpgrades.
+func (p PostgresqlDatabase) ApplyUpgrades(dir string, to string) error {
+	return applyIcingaDbUpgrades(p, dir, to)
+}
+
+// ImportSchema executes an SQL schema file, for example the Icinga DB or IDO schema.
+func (p PostgresqlDatabase) ImportSchema(schema string) error {
+	db, err := p.Open()
+	if err != nil {
+		return err
 	}
+	defer func() { _ = db.Close() }()
+
+	_, err = db.Exec(schema)
+	return err
 }
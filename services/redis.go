@@ -1,8 +1,15 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/icinga/icinga-testing/utils"
 	"github.com/redis/go-redis/v9"
+	"io"
+	"strings"
+	"testing"
+	"time"
 )
 
 type RedisServerBase interface {
@@ -12,6 +19,20 @@ type RedisServerBase interface {
 	// Port returns the port for connecting to this Redis server.
 	Port() string
 
+	// Exec runs a command in the container of the Redis server and returns its output and exit code. If stdin is not
+	// nil, it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
+	// ExitStatus returns the exit status of the Redis server and true if it exited, or false if it is still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the Redis server exited.
+	Exited() <-chan struct{}
+
+	// Stop stops the Redis server by sending SIGTERM and waits up to timeout for it to exit before killing it. The
+	// result contains its exit code and how long the shutdown took.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup stops and removes this Redis server.
 	Cleanup()
 }
@@ -30,3 +51,29 @@ func (r RedisServer) Open() *redis.Client {
 		Addr: r.Address(),
 	})
 }
+
+// Version returns the version of the Redis server as reported by the INFO command.
+func (r RedisServer) Version() (utils.Version, error) {
+	client := r.Open()
+	defer func() { _ = client.Close() }()
+
+	info, err := client.Info(context.Background(), "server").Result()
+	if err != nil {
+		return utils.Version{}, err
+	}
+
+	for _, line := range strings.Split(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			return utils.ParseVersion(v)
+		}
+	}
+
+	return utils.Version{}, errors.New("redis did not report its version")
+}
+
+// RequireVersion skips the test if the version of the Redis server does not satisfy the given constraint, for example
+// ">= 7". See utils.Version.Satisfies for the supported constraints.
+func (r RedisServer) RequireVersion(t testing.TB, constraint string) {
+	t.Helper()
+	requireVersion(t, "Redis", r.Version, constraint)
+}
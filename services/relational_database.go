@@ -1,5 +1,12 @@
 package services
 
+import (
+	"context"
+	"github.com/icinga/icinga-testing/utils"
+	"io"
+	"time"
+)
+
 type RelationalDatabase interface {
 	// Host returns the host for connecting to this database.
 	Host() string
@@ -25,9 +32,45 @@ type RelationalDatabase interface {
 	// DSN returns the data source name (DSN) to connect to this database from Go.
 	DSN() string
 
-	// ImportIcingaDbSchema imports the Icinga DB schema into this database.
+	// ImportIcingaDbSchema imports the Icinga DB schema into this database. Tests that only need a database with the
+	// current schema should use IT.IcingaDbDatabaseT instead, which copies it from a template database.
 	ImportIcingaDbSchema()
 
+	// ImportIcingaDbSchemaVersion imports the full Icinga DB schema of an older release, for example "1.1.0", into this
+	// database. The schema is taken from the corresponding tag, for example "v1.1.0", so dir has to be a git clone of
+	// Icinga DB including its tags, not just a checkout of the source like ICINGA_TESTING_ICINGADB_SOURCE may be.
+	ImportIcingaDbSchemaVersion(dir string, from string) error
+
+	// ApplyUpgrades applies the upgrade scripts from the schema/*/upgrades directory of the Icinga DB checkout in dir
+	// in order, up to and including the one for release to. Scripts for schema versions already recorded in the
+	// icingadb_schema table are skipped. Use CompareSchemas to check the result against a fresh import.
+	//
+	// Example usage:
+	//
+	//	require.NoError(t, upgraded.ImportIcingaDbSchemaVersion(source, "1.1.0"))
+	//	require.NoError(t, upgraded.ApplyUpgrades(source, "1.2.0"))
+	//	fresh.ImportIcingaDbSchema()
+	//	diff, err := services.CompareSchemas(context.Background(), fresh, upgraded)
+	//	require.NoError(t, err)
+	//	require.Empty(t, diff)
+	ApplyUpgrades(dir string, to string) error
+
+	// Exec runs a command in the container of the server hosting the database and returns its output and exit code. If
+	// stdin is not nil, it is passed to the command.
+	Exec(ctx context.Context, cmd []string, stdin io.Reader) (utils.ExecResult, error)
+
+	// ExitStatus returns the exit status of the server hosting the database and true if it exited, or false if it is
+	// still running.
+	ExitStatus() (utils.ExitStatus, bool)
+
+	// Exited returns a channel that is closed once the server hosting the database exited.
+	Exited() <-chan struct{}
+
+	// Stop stops the server hosting the database by sending SIGTERM and waits up to timeout for it to exit before
+	// killing it. As the server is shared with other databases, this should only be used by tests that check its
+	// shutdown and that do not use any other databases afterwards.
+	Stop(ctx context.Context, timeout time.Duration) (utils.StopResult, error)
+
 	// Cleanup removes the database.
 	Cleanup()
 }
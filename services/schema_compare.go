@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"github.com/icinga/icinga-testing/utils/schema"
+)
+
+// CompareSchemas reads the schemas of the databases a and b using schema.Read and returns their differences, one
+// human-readable line per differing object sorted by object, for example
+// "column host.name: A has varchar(255), B has text". No differences are returned if both schemas are equal.
+//
+// Both databases must be of the same type. Use schema.Compare with schema.WithNormalization for comparing a MySQL and
+// a PostgreSQL database or for inspecting the differences in more detail.
+func CompareSchemas(ctx context.Context, a RelationalDatabase, b RelationalDatabase) ([]string, error) {
+	diff, err := schema.Compare(ctx, a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(diff))
+	for _, d := range diff {
+		lines = append(lines, d.String())
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"github.com/icinga/icinga-testing/utils"
+	"testing"
+)
+
+// requireVersion implements the RequireVersion helper of the service handles using utils.RequireVersion.
+func requireVersion(t testing.TB, service string, version func() (utils.Version, error), constraint string) {
+	t.Helper()
+
+	v, err := version()
+	if err != nil {
+		t.Fatalf("failed to determine %s version: %v", service, err)
+	}
+
+	utils.RequireVersion(t, service, v, constraint)
+}
@@ -0,0 +1,393 @@
+// Package cluster implements the client side of the Icinga 2 cluster protocol so that tests can act as a fake cluster
+// endpoint connected to an Icinga 2 node.
+//
+// The protocol consists of JSON-RPC messages that are encoded as netstrings and sent over a TLS connection to the API
+// port of a node. The node identifies the endpoint by the common name of its client certificate, so for the node to
+// accept messages from it, that certificate has to be issued by the CA of the node and the endpoint has to be
+// declared in its config, see services.Icinga2.CA and services.Icinga2.WriteClusterEndpointConfig.
+//
+// Example usage:
+//
+//	node := it.Icinga2NodeT(t, "master")
+//	node.WriteClusterEndpointConfig("fake", "fake", "master")
+//	require.NoError(t, node.Reload())
+//
+//	roots := x509.NewCertPool()
+//	roots.AddCert(node.CA().Certificate)
+//	conn, err := cluster.Dial(ctx, node.Host()+":"+node.Port(), cluster.Config{
+//		Certificate: node.CA().MustNewCertificate("fake"),
+//		RootCAs:     roots,
+//		ServerName:  node.NodeName(),
+//	})
+//	require.NoError(t, err)
+//	defer conn.Close()
+//
+//	msg, err := conn.ReceiveMethod(ctx, "event::SetNextCheck")
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils/pki"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultVersion is the Icinga 2 version announced in the icinga::Hello message by default (2.14.0).
+	DefaultVersion = 21400
+
+	// DefaultHeartbeatInterval is the interval in which heartbeats are sent by default, same as Icinga 2 does.
+	DefaultHeartbeatInterval = 20 * time.Second
+)
+
+// Message is a single JSON-RPC message of the Icinga 2 cluster protocol.
+type Message struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+	Ts      float64         `json:"ts,omitempty"`
+}
+
+// DecodeParams unmarshals the params of the message into v.
+func (m Message) DecodeParams(v interface{}) error {
+	return json.Unmarshal(m.Params, v)
+}
+
+// Config configures a connection created by Dial.
+type Config struct {
+	// Certificate is the client certificate presented to the node. Its common name is the name of the endpoint.
+	Certificate *pki.CertificateWithKey
+
+	// RootCAs is used to verify the certificate of the node. If nil, the certificate of the node is not verified.
+	RootCAs *x509.CertPool
+
+	// ServerName is the expected common name of the certificate of the node. Only used if RootCAs is set.
+	ServerName string
+
+	// Version is the Icinga 2 version announced in the icinga::Hello message, encoded like Icinga 2 does, for example
+	// 21400 for 2.14.0. If 0, DefaultVersion is used. If negative, no icinga::Hello message is sent at all, like
+	// versions before 2.11 did.
+	Version int
+
+	// Capabilities announced in the icinga::Hello message.
+	Capabilities uint64
+
+	// HeartbeatInterval is the interval in which event::Heartbeat messages are sent. If 0, DefaultHeartbeatInterval
+	// is used. If negative, no heartbeats are sent automatically.
+	HeartbeatInterval time.Duration
+}
+
+// Conn is a connection to an Icinga 2 node acting as a cluster endpoint.
+type Conn struct {
+	conn       *tls.Conn
+	writeMutex sync.Mutex
+	messages   chan Message
+	done       chan struct{}
+	closeOnce  sync.Once
+	errMutex   sync.Mutex
+	err        error
+}
+
+// Dial connects to the API port of an Icinga 2 node at address and starts exchanging messages with it.
+func Dial(ctx context.Context, address string, config Config) (*Conn, error) {
+	if config.Certificate == nil {
+		return nil, errors.New("a client certificate is required")
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{config.Certificate.SignedCertificate},
+			PrivateKey:  config.Certificate.Key,
+		}},
+	}
+	if config.RootCAs != nil {
+		tlsConfig.RootCAs = config.RootCAs
+		tlsConfig.ServerName = config.ServerName
+	} else {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{}, Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{
+		conn:     conn.(*tls.Conn),
+		messages: make(chan Message, 1024),
+		done:     make(chan struct{}),
+	}
+
+	go c.readLoop()
+
+	if config.Version >= 0 {
+		version := config.Version
+		if version == 0 {
+			version = DefaultVersion
+		}
+		err := c.Send("icinga::Hello", map[string]interface{}{
+			"version":      version,
+			"capabilities": config.Capabilities,
+		})
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	if config.HeartbeatInterval >= 0 {
+		interval := config.HeartbeatInterval
+		if interval == 0 {
+			interval = DefaultHeartbeatInterval
+		}
+		go c.heartbeatLoop(interval)
+	}
+
+	return c, nil
+}
+
+// readLoop reads messages from the connection until it is closed.
+func (c *Conn) readLoop() {
+	defer close(c.messages)
+
+	r := bufio.NewReader(c.conn)
+	for {
+		data, err := ReadNetString(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.fail(fmt.Errorf("failed to decode message %q: %w", data, err))
+			return
+		}
+
+		select {
+		case c.messages <- msg:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// heartbeatLoop sends a heartbeat message every interval until the connection is closed.
+func (c *Conn) heartbeatLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.SendHeartbeat(120 * time.Second); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// fail records the first error that happened on the connection and closes it.
+func (c *Conn) fail(err error) {
+	c.errMutex.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.errMutex.Unlock()
+
+	_ = c.Close()
+}
+
+// Err returns the error that caused the connection to be closed, if any.
+func (c *Conn) Err() error {
+	c.errMutex.Lock()
+	defer c.errMutex.Unlock()
+
+	return c.err
+}
+
+// SendMessage sends a raw message to the node. If the jsonrpc field is empty, it is set to "2.0".
+func (c *Conn) SendMessage(msg Message) error {
+	if msg.JsonRpc == "" {
+		msg.JsonRpc = "2.0"
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// Encode the netstring into a buffer first so that it is sent in a single write.
+	var buf bytes.Buffer
+	if err := WriteNetString(&buf, data); err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_, err = c.conn.Write(buf.Bytes())
+	return err
+}
+
+// Send sends a message calling the given method with the given params to the node.
+func (c *Conn) Send(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return c.SendMessage(Message{Method: method, Params: p, Ts: unixFloat(time.Now())})
+}
+
+// Receive returns the next message received from the node.
+func (c *Conn) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			if err := c.Err(); err != nil {
+				return Message{}, err
+			}
+			return Message{}, net.ErrClosed
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// ReceiveMethod returns the next message received from the node for the given method, discarding all other messages.
+func (c *Conn) ReceiveMethod(ctx context.Context, method string) (Message, error) {
+	for {
+		msg, err := c.Receive(ctx)
+		if err != nil || msg.Method == method {
+			return msg, err
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// SendHeartbeat sends an event::Heartbeat message. The node closes the connection if it does not receive any further
+// message within the given timeout.
+func (c *Conn) SendHeartbeat(timeout time.Duration) error {
+	return c.Send("event::Heartbeat", map[string]interface{}{
+		"timeout": timeout.Seconds(),
+	})
+}
+
+// CheckResult is a check result as serialized in the event::CheckResult message.
+type CheckResult struct {
+	State           int       `json:"state"`
+	ExitStatus      int       `json:"exit_status"`
+	Output          string    `json:"output"`
+	PerformanceData []string  `json:"performance_data,omitempty"`
+	Command         []string  `json:"command,omitempty"`
+	CheckSource     string    `json:"check_source,omitempty"`
+	Active          bool      `json:"active"`
+	ScheduleStart   time.Time `json:"-"`
+	ScheduleEnd     time.Time `json:"-"`
+	ExecutionStart  time.Time `json:"-"`
+	ExecutionEnd    time.Time `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface, encoding timestamps as used by Icinga 2. Timestamps that are not
+// set default to the current time.
+func (cr CheckResult) MarshalJSON() ([]byte, error) {
+	type plain CheckResult
+
+	now := time.Now()
+	ts := func(t time.Time) float64 {
+		if t.IsZero() {
+			t = now
+		}
+		return unixFloat(t)
+	}
+
+	return json.Marshal(struct {
+		plain
+		Type           string  `json:"type"`
+		ScheduleStart  float64 `json:"schedule_start"`
+		ScheduleEnd    float64 `json:"schedule_end"`
+		ExecutionStart float64 `json:"execution_start"`
+		ExecutionEnd   float64 `json:"execution_end"`
+	}{
+		plain:          plain(cr),
+		Type:           "CheckResult",
+		ScheduleStart:  ts(cr.ScheduleStart),
+		ScheduleEnd:    ts(cr.ScheduleEnd),
+		ExecutionStart: ts(cr.ExecutionStart),
+		ExecutionEnd:   ts(cr.ExecutionEnd),
+	})
+}
+
+// checkableParams returns the params identifying a host or service. For hosts, service must be empty.
+func checkableParams(host string, service string) map[string]interface{} {
+	params := map[string]interface{}{"host": host}
+	if service != "" {
+		params["service"] = service
+	}
+	return params
+}
+
+// SendCheckResult sends an event::CheckResult message for a host or, if service is not empty, a service.
+func (c *Conn) SendCheckResult(host string, service string, cr CheckResult) error {
+	params := checkableParams(host, service)
+	params["cr"] = cr
+	return c.Send("event::CheckResult", params)
+}
+
+// SendSetNextCheck sends an event::SetNextCheck message for a host or, if service is not empty, a service.
+func (c *Conn) SendSetNextCheck(host string, service string, nextCheck time.Time) error {
+	params := checkableParams(host, service)
+	params["next_check"] = unixFloat(nextCheck)
+	return c.Send("event::SetNextCheck", params)
+}
+
+// SendConfigUpdate sends a config::Update message containing the given files for a zone. The keys of files are paths
+// within the synced zone directory as used by Icinga 2, for example "/_etc/hosts.conf".
+//
+// The node only accepts config updates from endpoints of its parent zone and only if accept_config is enabled.
+func (c *Conn) SendConfigUpdate(zone string, files map[string]string) error {
+	v1 := make(map[string]string, len(files))
+	v2 := make(map[string]string, len(files)+1)
+	for path, content := range files {
+		v1[strings.TrimPrefix(path, "/")] = content
+		v2[path] = content
+	}
+	v2["/.timestamp"] = fmt.Sprintf("%f", unixFloat(time.Now()))
+
+	return c.Send("config::Update", map[string]interface{}{
+		"update":    map[string]interface{}{zone: v1},
+		"update_v2": map[string]interface{}{zone: v2},
+	})
+}
+
+// unixFloat returns t as seconds since the epoch as used for timestamps by Icinga 2.
+func unixFloat(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxNetStringLength limits the size of a single message read from a connection. Icinga 2 itself uses the same limit.
+const maxNetStringLength = 64 * 1024 * 1024
+
+// WriteNetString writes data to w encoded as a netstring as used by the Icinga 2 cluster protocol: "<len>:<data>,".
+func WriteNetString(w io.Writer, data []byte) error {
+	if _, err := io.WriteString(w, strconv.Itoa(len(data))+":"); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, ",")
+	return err
+}
+
+// ReadNetString reads a single netstring from r and returns the data contained in it.
+func ReadNetString(r *bufio.Reader) ([]byte, error) {
+	length := 0
+	for digits := 0; ; digits++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && digits > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if c == ':' && digits > 0 {
+			break
+		} else if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid netstring length: unexpected character %q", c)
+		} else if digits > 0 && length == 0 {
+			return nil, fmt.Errorf("invalid netstring length: leading zero")
+		}
+
+		length = length*10 + int(c-'0')
+		if length > maxNetStringLength {
+			return nil, fmt.Errorf("netstring exceeds maximum length of %d bytes", maxNetStringLength)
+		}
+	}
+
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if data[length] != ',' {
+		return nil, fmt.Errorf("invalid netstring: expected ',' after data, got %q", data[length])
+	}
+
+	return data[:length], nil
+}
@@ -0,0 +1,40 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNetString(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range []string{`{"jsonrpc":"2.0"}`, "", "a,b:c"} {
+		if err := WriteNetString(&buf, []byte(s)); err != nil {
+			t.Fatalf("WriteNetString(%q) error = %v", s, err)
+		}
+	}
+
+	if got, want := buf.String(), `17:{"jsonrpc":"2.0"},0:,5:a,b:c,`; got != want {
+		t.Errorf("WriteNetString() wrote %q, want %q", got, want)
+	}
+
+	r := bufio.NewReader(&buf)
+	for _, want := range []string{`{"jsonrpc":"2.0"}`, "", "a,b:c"} {
+		got, err := ReadNetString(r)
+		if err != nil {
+			t.Fatalf("ReadNetString() error = %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("ReadNetString() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestReadNetStringInvalid(t *testing.T) {
+	for _, s := range []string{":", "x:", "01:a,", "3:abc;", "3:ab", "99999999999:"} {
+		if _, err := ReadNetString(bufio.NewReader(strings.NewReader(s))); err == nil {
+			t.Errorf("ReadNetString(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,634 @@
+// Package consistency compares the objects known to an Icinga 2 node with the rows Icinga DB wrote into its database.
+//
+// Example usage:
+//
+//	db, err := mysql.Open()
+//	require.NoError(t, err)
+//	defer db.Close()
+//
+//	eventually.Assert(t, func(t require.TestingT) {
+//		diff, err := consistency.Compare(ctx, icinga2.ApiClient(), db)
+//		require.NoError(t, err)
+//		assert.True(t, diff.Empty(), "Icinga DB should be consistent with Icinga 2:\n%s", diff)
+//	}, 20*time.Second, 1*time.Second)
+package consistency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Object types that can be compared.
+const (
+	Host         = "Host"
+	Service      = "Service"
+	HostGroup    = "HostGroup"
+	ServiceGroup = "ServiceGroup"
+	User         = "User"
+	UserGroup    = "UserGroup"
+	Downtime     = "Downtime"
+	Comment      = "Comment"
+	Dependency   = "Dependency"
+)
+
+// AllTypes contains all object types that are compared by default.
+var AllTypes = []string{Host, Service, HostGroup, ServiceGroup, User, UserGroup, Downtime, Comment, Dependency}
+
+// fetchers contains the functions loading the objects of each type from both sides, as well as the tables needed for
+// a type that do not exist in all Icinga DB schema versions.
+var fetchers = map[string]struct {
+	icinga2  func(ctx context.Context, c *utils.Icinga2Client) (objects, error)
+	icingaDb func(ctx context.Context, db *sql.DB) (objects, error)
+	tables   []string
+}{
+	Host:         {icinga2: icinga2Hosts, icingaDb: icingaDbHosts},
+	Service:      {icinga2: icinga2Services, icingaDb: icingaDbServices},
+	HostGroup:    {icinga2: icinga2Groups("hostgroups"), icingaDb: icingaDbGroups("hostgroup")},
+	ServiceGroup: {icinga2: icinga2Groups("servicegroups"), icingaDb: icingaDbGroups("servicegroup")},
+	User:         {icinga2: icinga2Users, icingaDb: icingaDbUsers},
+	UserGroup:    {icinga2: icinga2Groups("usergroups"), icingaDb: icingaDbGroups("usergroup")},
+	Downtime:     {icinga2: icinga2Downtimes, icingaDb: icingaDbDowntimes},
+	Comment:      {icinga2: icinga2Comments, icingaDb: icingaDbComments},
+	Dependency: {
+		icinga2:  icinga2Dependencies,
+		icingaDb: icingaDbDependencies,
+		tables:   []string{"dependency_node", "dependency_edge"},
+	},
+}
+
+// Compare fetches all objects of the given types (AllTypes if none are given) from the Icinga 2 API and compares them
+// with the rows in the Icinga DB database. It compares names, display names, custom variables, group memberships and
+// the state of hosts and services, as well as the authors and texts of downtimes and comments and the parent-child
+// relations of dependencies.
+//
+// If no types are given, types whose tables do not exist in the database are skipped, so that older Icinga DB schema
+// versions can be checked as well, for example ones without the dependency tables. Types given explicitly are always
+// compared.
+func Compare(ctx context.Context, c *utils.Icinga2Client, db *sql.DB, types ...string) (Diff, error) {
+	skipMissing := len(types) == 0
+	if skipMissing {
+		types = AllTypes
+	}
+
+	var diff Diff
+	for _, typ := range types {
+		f, ok := fetchers[typ]
+		if !ok {
+			return nil, fmt.Errorf("unsupported object type %q", typ)
+		}
+
+		if skipMissing && len(f.tables) > 0 {
+			exist, err := tablesExist(ctx, db, f.tables...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check tables of %s objects: %w", typ, err)
+			}
+			if !exist {
+				continue
+			}
+		}
+
+		icinga2, err := f.icinga2(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s objects from icinga2: %w", typ, err)
+		}
+
+		icingaDb, err := f.icingaDb(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s objects from icingadb database: %w", typ, err)
+		}
+
+		diff = append(diff, compare(typ, icinga2, icingaDb)...)
+	}
+
+	sortDiff(diff)
+	return diff, nil
+}
+
+// apiObject is a single object as returned by the /v1/objects API endpoints.
+type apiObject struct {
+	Name  string `json:"name"`
+	Attrs struct {
+		Name              string                 `json:"name"`
+		HostName          string                 `json:"host_name"`
+		DisplayName       string                 `json:"display_name"`
+		Vars              map[string]interface{} `json:"vars"`
+		Groups            []string               `json:"groups"`
+		State             float64                `json:"state"`
+		LastHardState     float64                `json:"last_hard_state"`
+		StateType         float64                `json:"state_type"`
+		LastCheckResult   json.RawMessage        `json:"last_check_result"`
+		Author            string                 `json:"author"`
+		Comment           string                 `json:"comment"`
+		Text              string                 `json:"text"`
+		ChildHostName     string                 `json:"child_host_name"`
+		ChildServiceName  string                 `json:"child_service_name"`
+		ParentHostName    string                 `json:"parent_host_name"`
+		ParentServiceName string                 `json:"parent_service_name"`
+	} `json:"attrs"`
+}
+
+// fetchIcinga2 returns all objects from the given /v1/objects endpoint, for example "hosts".
+func fetchIcinga2(ctx context.Context, c *utils.Icinga2Client, plural string) ([]apiObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/objects/"+plural, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	// Icinga 2 responds with 404 if there are no objects of the requested type at all.
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request for /v1/objects/%s failed with HTTP %s", plural, res.Status)
+	}
+
+	var data struct {
+		Results []apiObject `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return data.Results, nil
+}
+
+// checkableState sets the state fields of a host or service as Icinga DB writes them.
+func (o apiObject) checkableState(fields map[string]interface{}) {
+	if len(o.Attrs.LastCheckResult) == 0 || string(o.Attrs.LastCheckResult) == "null" {
+		// Icinga DB uses 99 for pending checkables that were not checked yet.
+		fields["soft_state"] = int64(99)
+		fields["hard_state"] = int64(99)
+		fields["state_type"] = "hard"
+		return
+	}
+
+	fields["soft_state"] = int64(o.Attrs.State)
+	fields["hard_state"] = int64(o.Attrs.LastHardState)
+	if o.Attrs.StateType == 1 {
+		fields["state_type"] = "hard"
+	} else {
+		fields["state_type"] = "soft"
+	}
+}
+
+func icinga2Hosts(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "hosts")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		fields := o.set(r.Name)
+		fields["display_name"] = r.Attrs.DisplayName
+		fields["vars"] = normalizeVars(r.Attrs.Vars)
+		fields["groups"] = sortedStrings(r.Attrs.Groups)
+		r.checkableState(fields)
+	}
+
+	return o, nil
+}
+
+func icinga2Services(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "services")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		fields := o.set(r.Attrs.HostName + "!" + r.Attrs.Name)
+		fields["display_name"] = r.Attrs.DisplayName
+		fields["vars"] = normalizeVars(r.Attrs.Vars)
+		fields["groups"] = sortedStrings(r.Attrs.Groups)
+		r.checkableState(fields)
+	}
+
+	return o, nil
+}
+
+func icinga2Groups(plural string) func(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	return func(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+		results, err := fetchIcinga2(ctx, c, plural)
+		if err != nil {
+			return nil, err
+		}
+
+		o := make(objects)
+		for _, r := range results {
+			fields := o.set(r.Name)
+			fields["display_name"] = r.Attrs.DisplayName
+			fields["vars"] = normalizeVars(r.Attrs.Vars)
+		}
+
+		return o, nil
+	}
+}
+
+func icinga2Users(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "users")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		fields := o.set(r.Name)
+		fields["display_name"] = r.Attrs.DisplayName
+		fields["vars"] = normalizeVars(r.Attrs.Vars)
+		fields["groups"] = sortedStrings(r.Attrs.Groups)
+	}
+
+	return o, nil
+}
+
+func icinga2Downtimes(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "downtimes")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		fields := o.set(r.Name)
+		fields["author"] = r.Attrs.Author
+		fields["comment"] = r.Attrs.Comment
+	}
+
+	return o, nil
+}
+
+func icinga2Comments(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "comments")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		fields := o.set(r.Name)
+		fields["author"] = r.Attrs.Author
+		fields["text"] = r.Attrs.Text
+	}
+
+	return o, nil
+}
+
+func icinga2Dependencies(ctx context.Context, c *utils.Icinga2Client) (objects, error) {
+	results, err := fetchIcinga2(ctx, c, "dependencies")
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for _, r := range results {
+		o.set(dependencyName(
+			checkableName(r.Attrs.ChildHostName, r.Attrs.ChildServiceName),
+			checkableName(r.Attrs.ParentHostName, r.Attrs.ParentServiceName)))
+	}
+
+	return o, nil
+}
+
+// checkableName returns the full name of a host or, if service is not empty, of a service.
+func checkableName(host string, service string) string {
+	if service == "" {
+		return host
+	}
+	return host + "!" + service
+}
+
+// dependencyName returns the name used for comparing dependencies. Dependencies are compared by the relation between
+// child and parent as Icinga DB does not store the names of the Dependency objects.
+func dependencyName(child string, parent string) string {
+	return child + " -> " + parent
+}
+
+// tablesExist reports whether all given tables exist in the current database (MySQL) or schema (PostgreSQL).
+func tablesExist(ctx context.Context, db *sql.DB, tables ...string) (bool, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT version()").Scan(&version); err != nil {
+		return false, err
+	}
+	// MySQL and MariaDB report just the version number, PostgreSQL prefixes it with its name.
+	current := "DATABASE()"
+	if strings.HasPrefix(version, "PostgreSQL") {
+		current = "current_schema()"
+	}
+
+	for _, table := range tables {
+		// The table names are constants, so they can be part of the query, which avoids the differing placeholder
+		// syntax of MySQL and PostgreSQL.
+		var n int
+		err := db.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = %s AND table_name = '%s'",
+			current, table)).Scan(&n)
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// query runs a query and calls f for every row. The values are scanned into dest before calling f.
+func query(ctx context.Context, db *sql.DB, q string, f func(), dest ...interface{}) error {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to execute query %q: %w", q, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan row of query %q: %w", q, err)
+		}
+		f()
+	}
+
+	return rows.Err()
+}
+
+// icingaDbCustomvars adds the custom variables from the given table linking objects to custom variables to the objects.
+// The query must return the object name, the custom variable name and its JSON-encoded value.
+func icingaDbCustomvars(ctx context.Context, db *sql.DB, o objects, q string) error {
+	for _, fields := range o {
+		fields["vars"] = map[string]interface{}{}
+	}
+
+	var object, name, value string
+	return query(ctx, db, q, func() {
+		if fields, ok := o[object]; ok {
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				v = value
+			}
+			fields["vars"].(map[string]interface{})[name] = v
+		}
+	}, &object, &name, &value)
+}
+
+// icingaDbGroupMembers adds the names of the groups to the objects. The query must return the object and group name.
+func icingaDbGroupMembers(ctx context.Context, db *sql.DB, o objects, q string) error {
+	groups := make(map[string][]string)
+
+	var object, group string
+	err := query(ctx, db, q, func() {
+		groups[object] = append(groups[object], group)
+	}, &object, &group)
+	if err != nil {
+		return err
+	}
+
+	for name, fields := range o {
+		fields["groups"] = sortedStrings(groups[name])
+	}
+
+	return nil
+}
+
+// icingaDbCheckableState sets the state fields of a host or service from the scanned values.
+func icingaDbCheckableState(
+	fields map[string]interface{}, softState, hardState sql.NullInt64, stateType sql.NullString,
+) {
+	fields["soft_state"] = nil
+	if softState.Valid {
+		fields["soft_state"] = softState.Int64
+	}
+	fields["hard_state"] = nil
+	if hardState.Valid {
+		fields["hard_state"] = hardState.Int64
+	}
+	fields["state_type"] = nil
+	if stateType.Valid {
+		fields["state_type"] = stateType.String
+	}
+}
+
+func icingaDbHosts(ctx context.Context, db *sql.DB) (objects, error) {
+	o := make(objects)
+
+	var name, displayName, stateType sql.NullString
+	var softState, hardState sql.NullInt64
+	err := query(ctx, db, `SELECT h.name, h.display_name, s.soft_state, s.hard_state, s.state_type
+		FROM host h LEFT JOIN host_state s ON s.host_id = h.id`, func() {
+		fields := o.set(name.String)
+		fields["display_name"] = displayName.String
+		icingaDbCheckableState(fields, softState, hardState, stateType)
+	}, &name, &displayName, &softState, &hardState, &stateType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbCustomvars(ctx, db, o, `SELECT h.name, c.name, c.value FROM host_customvar hc
+		JOIN host h ON h.id = hc.host_id JOIN customvar c ON c.id = hc.customvar_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbGroupMembers(ctx, db, o, `SELECT h.name, g.name FROM hostgroup_member m
+		JOIN host h ON h.id = m.host_id JOIN hostgroup g ON g.id = m.hostgroup_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func icingaDbServices(ctx context.Context, db *sql.DB) (objects, error) {
+	o := make(objects)
+
+	var host, name, displayName, stateType sql.NullString
+	var softState, hardState sql.NullInt64
+	err := query(ctx, db, `SELECT h.name, s.name, s.display_name, st.soft_state, st.hard_state, st.state_type
+		FROM service s JOIN host h ON h.id = s.host_id LEFT JOIN service_state st ON st.service_id = s.id`, func() {
+		fields := o.set(host.String + "!" + name.String)
+		fields["display_name"] = displayName.String
+		icingaDbCheckableState(fields, softState, hardState, stateType)
+	}, &host, &name, &displayName, &softState, &hardState, &stateType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbCustomvars(ctx, db, o, `SELECT CONCAT(h.name, '!', s.name), c.name, c.value FROM service_customvar sc
+		JOIN service s ON s.id = sc.service_id JOIN host h ON h.id = s.host_id
+		JOIN customvar c ON c.id = sc.customvar_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbGroupMembers(ctx, db, o, `SELECT CONCAT(h.name, '!', s.name), g.name FROM servicegroup_member m
+		JOIN service s ON s.id = m.service_id JOIN host h ON h.id = s.host_id
+		JOIN servicegroup g ON g.id = m.servicegroup_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func icingaDbGroups(table string) func(ctx context.Context, db *sql.DB) (objects, error) {
+	return func(ctx context.Context, db *sql.DB) (objects, error) {
+		o := make(objects)
+
+		var name, displayName string
+		err := query(ctx, db, fmt.Sprintf(`SELECT name, display_name FROM %s`, table), func() {
+			o.set(name)["display_name"] = displayName
+		}, &name, &displayName)
+		if err != nil {
+			return nil, err
+		}
+
+		err = icingaDbCustomvars(ctx, db, o, fmt.Sprintf(`SELECT g.name, c.name, c.value FROM %[1]s_customvar gc
+			JOIN %[1]s g ON g.id = gc.%[1]s_id JOIN customvar c ON c.id = gc.customvar_id`, table))
+		if err != nil {
+			return nil, err
+		}
+
+		return o, nil
+	}
+}
+
+func icingaDbUsers(ctx context.Context, db *sql.DB) (objects, error) {
+	o := make(objects)
+
+	var name, displayName string
+	err := query(ctx, db, `SELECT name, display_name FROM "user"`, func() {
+		o.set(name)["display_name"] = displayName
+	}, &name, &displayName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbCustomvars(ctx, db, o, `SELECT u.name, c.name, c.value FROM user_customvar uc
+		JOIN "user" u ON u.id = uc.user_id JOIN customvar c ON c.id = uc.customvar_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = icingaDbGroupMembers(ctx, db, o, `SELECT u.name, g.name FROM usergroup_member m
+		JOIN "user" u ON u.id = m.user_id JOIN usergroup g ON g.id = m.usergroup_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func icingaDbDowntimes(ctx context.Context, db *sql.DB) (objects, error) {
+	o := make(objects)
+
+	var name, author, comment string
+	err := query(ctx, db, `SELECT name, author, comment FROM downtime`, func() {
+		fields := o.set(name)
+		fields["author"] = author
+		fields["comment"] = comment
+	}, &name, &author, &comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func icingaDbComments(ctx context.Context, db *sql.DB) (objects, error) {
+	o := make(objects)
+
+	var name, author, text string
+	err := query(ctx, db, `SELECT name, author, text FROM comment`, func() {
+		fields := o.set(name)
+		fields["author"] = author
+		fields["text"] = text
+	}, &name, &author, &text)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func icingaDbDependencies(ctx context.Context, db *sql.DB) (objects, error) {
+	// Nodes of the dependency graph are either hosts, services or redundancy groups. Edges point from the child to
+	// the parent. If a dependency is part of a redundancy group, there is an edge from the child to the redundancy
+	// group and from there to the parent.
+	checkables := make(map[string]string)
+
+	var id []byte
+	var host, serviceHost, service sql.NullString
+	err := query(ctx, db, `SELECT n.id, h.name, sh.name, s.name FROM dependency_node n
+		LEFT JOIN host h ON h.id = n.host_id
+		LEFT JOIN service s ON s.id = n.service_id LEFT JOIN host sh ON sh.id = s.host_id`, func() {
+		if service.Valid {
+			checkables[hex.EncodeToString(id)] = checkableName(serviceHost.String, service.String)
+		} else if host.Valid {
+			checkables[hex.EncodeToString(id)] = host.String
+		}
+	}, &id, &host, &serviceHost, &service)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make(map[string][]string)
+	var from, to []byte
+	err = query(ctx, db, `SELECT from_node_id, to_node_id FROM dependency_edge`, func() {
+		edges[hex.EncodeToString(from)] = append(edges[hex.EncodeToString(from)], hex.EncodeToString(to))
+	}, &from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(objects)
+	for from, tos := range edges {
+		child, ok := checkables[from]
+		if !ok {
+			continue
+		}
+
+		for _, to := range tos {
+			if parent, ok := checkables[to]; ok {
+				o.set(dependencyName(child, parent))
+			} else {
+				// Redundancy group, follow its edges to the parents.
+				for _, groupParent := range edges[to] {
+					if parent, ok := checkables[groupParent]; ok {
+						o.set(dependencyName(child, parent))
+					}
+				}
+			}
+		}
+	}
+
+	return o, nil
+}
+
+// normalizeVars returns vars or an empty map if it is nil so that no custom variables compare equal on both sides.
+func normalizeVars(vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		return map[string]interface{}{}
+	}
+	return vars
+}
+
+// sortedStrings returns a sorted copy of in, which is never nil.
+func sortedStrings(in []string) []string {
+	out := append([]string{}, in...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,149 @@
+package consistency
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DifferenceKind describes how an object differs between Icinga 2 and Icinga DB.
+type DifferenceKind int
+
+const (
+	// MissingInIcingaDb means that an object exists in Icinga 2 but not in the Icinga DB database.
+	MissingInIcingaDb DifferenceKind = iota
+
+	// MissingInIcinga2 means that an object exists in the Icinga DB database but not in Icinga 2.
+	MissingInIcinga2
+
+	// FieldMismatch means that an object exists on both sides but the value of one of its fields differs.
+	FieldMismatch
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case MissingInIcingaDb:
+		return "missing in Icinga DB"
+	case MissingInIcinga2:
+		return "missing in Icinga 2"
+	case FieldMismatch:
+		return "field mismatch"
+	default:
+		return fmt.Sprintf("DifferenceKind(%d)", int(k))
+	}
+}
+
+// Difference is a single difference between Icinga 2 and Icinga DB.
+type Difference struct {
+	Kind DifferenceKind
+
+	// Type is the Icinga 2 object type, for example "Host".
+	Type string
+
+	// Name is the full name of the object, for example "host!service" for services.
+	Name string
+
+	// Field is the name of the differing field, only set for FieldMismatch.
+	Field string
+
+	// Icinga2 and IcingaDb are the values of the field on both sides, only set for FieldMismatch.
+	Icinga2  interface{}
+	IcingaDb interface{}
+}
+
+func (d Difference) String() string {
+	if d.Kind == FieldMismatch {
+		return fmt.Sprintf("%s %q: %s differs: Icinga 2 has %#v, Icinga DB has %#v",
+			d.Type, d.Name, d.Field, d.Icinga2, d.IcingaDb)
+	}
+	return fmt.Sprintf("%s %q: %s", d.Type, d.Name, d.Kind)
+}
+
+// Diff is the result of comparing Icinga 2 with Icinga DB. It is sorted by type, name and field.
+type Diff []Difference
+
+// Empty returns whether no differences were found.
+func (d Diff) Empty() bool {
+	return len(d) == 0
+}
+
+// String returns a human-readable representation with one difference per line.
+func (d Diff) String() string {
+	var b strings.Builder
+	for _, difference := range d {
+		b.WriteString(difference.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// objects maps object names to their fields.
+type objects map[string]map[string]interface{}
+
+// set returns the fields of the given object, creating it if it does not exist yet.
+func (o objects) set(name string) map[string]interface{} {
+	fields, ok := o[name]
+	if !ok {
+		fields = make(map[string]interface{})
+		o[name] = fields
+	}
+	return fields
+}
+
+// compare returns the differences between the objects of one type from Icinga 2 and Icinga DB. Fields missing on
+// one side are treated as nil.
+func compare(typ string, icinga2 objects, icingaDb objects) Diff {
+	var diff Diff
+
+	for name, fields := range icinga2 {
+		dbFields, ok := icingaDb[name]
+		if !ok {
+			diff = append(diff, Difference{Kind: MissingInIcingaDb, Type: typ, Name: name})
+			continue
+		}
+
+		keys := make(map[string]struct{})
+		for k := range fields {
+			keys[k] = struct{}{}
+		}
+		for k := range dbFields {
+			keys[k] = struct{}{}
+		}
+
+		for k := range keys {
+			if !reflect.DeepEqual(fields[k], dbFields[k]) {
+				diff = append(diff, Difference{
+					Kind:     FieldMismatch,
+					Type:     typ,
+					Name:     name,
+					Field:    k,
+					Icinga2:  fields[k],
+					IcingaDb: dbFields[k],
+				})
+			}
+		}
+	}
+
+	for name := range icingaDb {
+		if _, ok := icinga2[name]; !ok {
+			diff = append(diff, Difference{Kind: MissingInIcinga2, Type: typ, Name: name})
+		}
+	}
+
+	return diff
+}
+
+// sortDiff sorts the differences by type, name and field.
+func sortDiff(diff Diff) {
+	sort.Slice(diff, func(i, j int) bool {
+		a, b := diff[i], diff[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Field < b.Field
+	})
+}
@@ -0,0 +1,42 @@
+package consistency
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	icinga2 := objects{
+		"a": {"display_name": "A", "groups": []string{"x", "y"}},
+		"b": {"display_name": "B"},
+		"c": {"display_name": "C"},
+	}
+	icingaDb := objects{
+		"a": {"display_name": "A", "groups": []string{"x"}},
+		"b": {"display_name": "B", "vars": map[string]interface{}{}},
+		"d": {"display_name": "D"},
+	}
+
+	diff := compare("Host", icinga2, icingaDb)
+	sortDiff(diff)
+
+	want := Diff{
+		{Kind: FieldMismatch, Type: "Host", Name: "a", Field: "groups",
+			Icinga2: []string{"x", "y"}, IcingaDb: []string{"x"}},
+		{Kind: FieldMismatch, Type: "Host", Name: "b", Field: "vars",
+			Icinga2: nil, IcingaDb: map[string]interface{}{}},
+		{Kind: MissingInIcingaDb, Type: "Host", Name: "c"},
+		{Kind: MissingInIcinga2, Type: "Host", Name: "d"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("compare() = %v, want %v", diff, want)
+	}
+}
+
+func TestCompareEqual(t *testing.T) {
+	o := objects{"a": {"vars": map[string]interface{}{"k": []interface{}{1.0, "v"}}}}
+
+	if diff := compare("Host", o, o); !diff.Empty() {
+		t.Errorf("compare() = %v, want empty diff", diff)
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
@@ -78,6 +79,45 @@ func DockerExec(
 	ctx context.Context, client *client.Client, logger *zap.Logger, containerId string,
 	cmd []string, stdin io.Reader, stdout io.Writer, stderr io.Writer,
 ) error {
+	exitCode, err := dockerExec(ctx, client, logger, containerId, cmd, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("command exited with code %d", exitCode)
+	}
+
+	return nil
+}
+
+// ExecResult contains the output and exit code of a command run using DockerExecResult.
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// DockerExecResult runs a command in a container, passing stdin to it (if not nil), and waits for it to complete.
+// Unlike DockerExec, a non-zero exit code is not treated as an error but returned as part of the result together with
+// the output of the command. An error is only returned if the command could not be run.
+func DockerExecResult(
+	ctx context.Context, client *client.Client, logger *zap.Logger, containerId string, cmd []string, stdin io.Reader,
+) (ExecResult, error) {
+	var stdout, stderr bytes.Buffer
+
+	exitCode, err := dockerExec(ctx, client, logger, containerId, cmd, stdin, &stdout, &stderr)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	return ExecResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitCode: exitCode}, nil
+}
+
+// dockerExec implements DockerExec and DockerExecResult and returns the exit code of the command.
+func dockerExec(
+	ctx context.Context, client *client.Client, logger *zap.Logger, containerId string,
+	cmd []string, stdin io.Reader, stdout io.Writer, stderr io.Writer,
+) (int, error) {
 	logger = logger.With(zap.String("container-id", containerId), zap.Strings("container-exec-cmd", cmd))
 
 	logger.Debug("executing command in container")
@@ -98,7 +138,7 @@ func DockerExec(
 		Detach:       true,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create container exec: %w", err)
+		return 0, fmt.Errorf("failed to create container exec: %w", err)
 	}
 	logger = logger.With(zap.String("container-exec-id", exec.ID))
 	logger.Debug("created exec")
@@ -107,7 +147,7 @@ func DockerExec(
 
 	attach, err := client.ContainerExecAttach(gCtx, exec.ID, types.ExecStartCheck{})
 	if err != nil {
-		return fmt.Errorf("failed to attach to container exec: %w", err)
+		return 0, fmt.Errorf("failed to attach to container exec: %w", err)
 	}
 	logger.Debug("attached to exec")
 
@@ -153,21 +193,18 @@ func DockerExec(
 	})
 
 	if err := g.Wait(); err != nil {
-		return err
+		return 0, err
 	}
 
 	inspect, err := client.ContainerExecInspect(ctx, exec.ID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if inspect.Running {
 		panic("command should no longer be running")
 	}
-	if inspect.ExitCode != 0 {
-		return fmt.Errorf("command exited with code %d", inspect.ExitCode)
-	}
 
-	return nil
+	return inspect.ExitCode, nil
 }
 
 // DockerImagePull pulls an image from the registry. If force=false, no pull is done if the image already exists.
@@ -197,3 +234,20 @@ func DockerImagePull(ctx context.Context, logger *zap.Logger, dockerClient *clie
 
 	return pull.Close()
 }
+
+// DockerNetworkGateway returns the gateway address of a docker network. On Linux, this is an address of the host
+// running the Docker daemon that can be reached from within the containers attached to that network.
+func DockerNetworkGateway(ctx context.Context, client *client.Client, id string) (string, error) {
+	net, err := client.NetworkInspect(ctx, id, types.NetworkInspectOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	for _, config := range net.IPAM.Config {
+		if config.Gateway != "" {
+			return config.Gateway, nil
+		}
+	}
+
+	return "", fmt.Errorf("no gateway found for network %s", id)
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// DockerFileInfo describes a file within a container as returned by DockerListDir.
+type DockerFileInfo struct {
+	Name       string
+	Size       int64
+	Mode       fs.FileMode
+	Uid        int
+	Gid        int
+	Owner      string
+	Group      string
+	ModTime    time.Time
+	LinkTarget string
+}
+
+// IsDir reports whether the file is a directory.
+func (f DockerFileInfo) IsDir() bool {
+	return f.Mode.IsDir()
+}
+
+// DockerCopyFromContainer returns a tar archive of the file or directory at srcPath within a container.
+func DockerCopyFromContainer(
+	ctx context.Context, client *client.Client, containerId string, srcPath string,
+) (io.ReadCloser, error) {
+	r, _, err := client.CopyFromContainer(ctx, containerId, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %q from container: %w", srcPath, err)
+	}
+	return r, nil
+}
+
+// DockerCopyToContainer extracts a tar archive into the directory dstDir within a container. File modes and ownership
+// are taken from the archive.
+func DockerCopyToContainer(
+	ctx context.Context, client *client.Client, containerId string, dstDir string, tarball io.Reader,
+) error {
+	err := client.CopyToContainer(ctx, containerId, dstDir, tarball, types.CopyToContainerOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to copy to %q in container: %w", dstDir, err)
+	}
+	return nil
+}
+
+// DockerReadFile returns the contents of a regular file within a container.
+func DockerReadFile(ctx context.Context, client *client.Client, containerId string, file string) ([]byte, error) {
+	r, err := DockerCopyFromContainer(ctx, client, containerId, file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	return readTarFile(r)
+}
+
+// DockerListDir returns information on the files directly within a directory within a container.
+func DockerListDir(
+	ctx context.Context, client *client.Client, containerId string, dir string,
+) ([]DockerFileInfo, error) {
+	r, err := DockerCopyFromContainer(ctx, client, containerId, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	return listTarDir(r)
+}
+
+// readTarFile returns the contents of the first entry of a tar archive, which must be a regular file.
+func readTarFile(r io.Reader) ([]byte, error) {
+	tr := tar.NewReader(r)
+
+	h, err := tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("archive is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	if h.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("%q is not a regular file", h.Name)
+	}
+
+	return io.ReadAll(tr)
+}
+
+// listTarDir returns the entries directly within the directory that is the first entry of a tar archive, as created
+// by the Docker API when copying a directory from a container.
+func listTarDir(r io.Reader) ([]DockerFileInfo, error) {
+	tr := tar.NewReader(r)
+
+	root, err := tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("archive is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	if root.Typeflag != tar.TypeDir {
+		return nil, fmt.Errorf("%q is not a directory", root.Name)
+	}
+	prefix := strings.TrimSuffix(root.Name, "/") + "/"
+
+	files := make([]DockerFileInfo, 0)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(h.Name, prefix), "/")
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		files = append(files, DockerFileInfo{
+			Name:       path.Base(name),
+			Size:       h.Size,
+			Mode:       h.FileInfo().Mode(),
+			Uid:        h.Uid,
+			Gid:        h.Gid,
+			Owner:      h.Uname,
+			Group:      h.Gname,
+			ModTime:    h.ModTime,
+			LinkTarget: h.Linkname,
+		})
+	}
+}
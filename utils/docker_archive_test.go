@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+)
+
+func TestListTarDir(t *testing.T) {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, h := range []tar.Header{
+		{Name: "api/", Typeflag: tar.TypeDir, Mode: 0750},
+		{Name: "api/zones/", Typeflag: tar.TypeDir, Mode: 0750, Uid: 5665, Uname: "icinga"},
+		{Name: "api/zones/master/_etc/hosts.conf", Typeflag: tar.TypeReg, Mode: 0640},
+		{Name: "api/log/current", Typeflag: tar.TypeReg, Mode: 0640},
+		{Name: "api/packages", Typeflag: tar.TypeSymlink, Linkname: "zones"},
+	} {
+		h := h
+		if err := w.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listTarDir(&buf)
+	if err != nil {
+		t.Fatalf("listTarDir() error = %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("listTarDir() returned %d files, want 2: %+v", len(files), files)
+	}
+	if f := files[0]; f.Name != "zones" || !f.IsDir() || f.Mode.Perm() != 0750 || f.Uid != 5665 || f.Owner != "icinga" {
+		t.Errorf("listTarDir()[0] = %+v", f)
+	}
+	if f := files[1]; f.Name != "packages" || f.LinkTarget != "zones" {
+		t.Errorf("listTarDir()[1] = %+v", f)
+	}
+}
+
+func TestReadTarFile(t *testing.T) {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	content := []byte("const NodeName = \"master\"\n")
+	header := &tar.Header{Name: "constants.conf", Typeflag: tar.TypeReg, Size: int64(len(content))}
+	if err := w.WriteHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readTarFile(&buf)
+	if err != nil {
+		t.Fatalf("readTarFile() error = %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("readTarFile() = %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExitOutputLines is the number of output lines included in an ExitStatus.
+const ExitOutputLines = 50
+
+// ExitStatus describes how a container exited.
+type ExitStatus struct {
+	// ExitCode is the exit code of the main process of the container.
+	ExitCode int
+
+	// Error is set if the exit status could not be determined, for example because the container was removed.
+	Error string
+
+	// Expected is set if the container was stopped on purpose, for example by Cleanup, and did not crash.
+	Expected bool
+
+	// Output contains up to ExitOutputLines of the last lines the container has written before it exited.
+	Output []string
+}
+
+// StopResult describes how a container shut down after DockerContainerWatch.Stop asked it to.
+type StopResult struct {
+	ExitStatus
+
+	// Duration is the time from sending SIGTERM until the container exited.
+	Duration time.Duration
+
+	// TimedOut is set if the container did not exit within the timeout and was killed using SIGKILL.
+	TimedOut bool
+}
+
+// DockerContainerWatch watches a container until it exits.
+type DockerContainerWatch struct {
+	client      *client.Client
+	containerId string
+	done        chan struct{}
+	output      *LineRecorder
+	expected    atomic.Bool
+	mutex       sync.Mutex
+	status      ExitStatus
+}
+
+// DockerWatchContainer starts watching a container. It must be called before the container is started, otherwise an
+// exit happening in between may be missed. If output is not nil, the last lines recorded by it are included in the
+// exit status.
+func DockerWatchContainer(client *client.Client, containerId string, output *LineRecorder) *DockerContainerWatch {
+	w := &DockerContainerWatch{client: client, containerId: containerId, done: make(chan struct{}), output: output}
+
+	waitCh, errCh := client.ContainerWait(context.Background(), containerId, container.WaitConditionNextExit)
+
+	go func() {
+		defer close(w.done)
+
+		var status ExitStatus
+		select {
+		case res := <-waitCh:
+			status.ExitCode = int(res.StatusCode)
+			if res.Error != nil {
+				status.Error = res.Error.Message
+			}
+		case err := <-errCh:
+			status.ExitCode = -1
+			status.Error = err.Error()
+		}
+
+		w.mutex.Lock()
+		w.status = status
+		w.mutex.Unlock()
+	}()
+
+	return w
+}
+
+// Expect marks the next exit of the container as expected. It must be called before intentionally stopping, killing
+// or removing the container, so that this is not reported as a crash.
+func (w *DockerContainerWatch) Expect() {
+	w.expected.Store(true)
+}
+
+// Done returns a channel that is closed once the container exited.
+func (w *DockerContainerWatch) Done() <-chan struct{} {
+	return w.done
+}
+
+// ExitStatus returns the exit status of the container and true if it exited, or false if it is still running.
+func (w *DockerContainerWatch) ExitStatus() (ExitStatus, bool) {
+	select {
+	case <-w.done:
+		w.mutex.Lock()
+		status := w.status
+		w.mutex.Unlock()
+
+		status.Expected = w.expected.Load()
+		if w.output != nil {
+			// The output is read when requested instead of at the time of the exit, as the last lines may still be
+			// in transit from the Docker daemon when the exit is noticed.
+			status.Output = w.output.Last(ExitOutputLines)
+		}
+
+		return status, true
+	default:
+		return ExitStatus{}, false
+	}
+}
+
+// Stop sends SIGTERM to the container and waits up to timeout for it to exit. If it does not exit in time, it is
+// killed using SIGKILL. The exit is marked as expected. If the container already exited, its exit status is returned
+// right away.
+func (w *DockerContainerWatch) Stop(ctx context.Context, timeout time.Duration) (StopResult, error) {
+	w.Expect()
+
+	if status, exited := w.ExitStatus(); exited {
+		return StopResult{ExitStatus: status}, nil
+	}
+
+	start := time.Now()
+	if err := w.client.ContainerKill(ctx, w.containerId, "SIGTERM"); err != nil {
+		return StopResult{}, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	result := StopResult{}
+	select {
+	case <-w.done:
+	case <-timer.C:
+		result.TimedOut = true
+		if err := w.client.ContainerKill(ctx, w.containerId, "SIGKILL"); err != nil {
+			return StopResult{}, err
+		}
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			return StopResult{}, ctx.Err()
+		}
+	case <-ctx.Done():
+		return StopResult{}, ctx.Err()
+	}
+
+	result.Duration = time.Since(start)
+	result.ExitStatus, _ = w.ExitStatus()
+
+	return result, nil
+}
@@ -0,0 +1,143 @@
+// Package faketime allows controlling the clock of processes running in containers using libfaketime.
+//
+// A Clock is backed by a directory containing a libfaketime timestamp file. Containers opting in to use a Clock get
+// this directory bind-mounted and libfaketime preloaded with FAKETIME_NO_CACHE set, so that every change made using
+// Clock.Advance or Clock.SetSpeed is picked up immediately by all of them.
+//
+// Note that libfaketime only works for programs that obtain the current time using the C library. Icinga DB is a
+// statically linked Go program that reads the time using the vDSO or raw system calls, which libfaketime cannot
+// intercept. Instead, Icinga DB can be built with a time package that reads the timestamp file itself, see Clock.GoEnv.
+// It only applies the clock to the current time returned by time.Now, timers and sleeps keep using the real time.
+package faketime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// ContainerDir is the path where the directory of a Clock is mounted within a container.
+	ContainerDir = "/icinga-testing-faketime"
+
+	// ContainerLibrary is the path where libfaketime is mounted within a container.
+	ContainerLibrary = ContainerDir + "-lib/libfaketime.so.1"
+
+	// timestampFile is the name of the libfaketime timestamp file within the directory of a Clock.
+	timestampFile = "faketimerc"
+)
+
+// Clock controls the time seen by all processes that use it.
+type Clock struct {
+	mutex  sync.Mutex
+	dir    string
+	offset time.Duration
+	speed  float64
+}
+
+// NewClock creates a new Clock that initially matches the real time.
+func NewClock() (*Clock, error) {
+	dir, err := os.MkdirTemp("", "icinga-testing-faketime")
+	if err != nil {
+		return nil, err
+	}
+	// Processes in the containers may run as a different user, so they must be able to read the timestamp file.
+	if err := os.Chmod(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &Clock{dir: dir, speed: 1}
+	if err := c.write(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Dir returns the directory on the host that has to be mounted at ContainerDir.
+func (c *Clock) Dir() string {
+	return c.dir
+}
+
+// Env returns the environment variables that have to be set in a container to use this clock.
+func (c *Clock) Env() []string {
+	return []string{
+		"LD_PRELOAD=" + ContainerLibrary,
+		"FAKETIME_TIMESTAMP_FILE=" + ContainerDir + "/" + timestampFile,
+		"FAKETIME_NO_CACHE=1",
+	}
+}
+
+// GoEnv is like Env, but for Go programs built with a time package reading the timestamp file itself, like Icinga DB
+// instances using services.WithIcingaDbClock. Those do not use libfaketime, so it is not preloaded.
+func (c *Clock) GoEnv() []string {
+	return []string{
+		"FAKETIME_TIMESTAMP_FILE=" + ContainerDir + "/" + timestampFile,
+	}
+}
+
+// Advance moves the time of all processes using this clock forward by d.
+func (c *Clock) Advance(d time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.offset += d
+	return c.write()
+}
+
+// SetSpeed sets the factor by which the time of all processes using this clock passes faster than the real time.
+// Note that libfaketime applies the factor relative to the start of each individual process.
+func (c *Clock) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got %g", speed)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.speed = speed
+	return c.write()
+}
+
+// Offset returns the total duration by which the clock was advanced.
+func (c *Clock) Offset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.offset
+}
+
+// Cleanup removes the directory backing the clock.
+func (c *Clock) Cleanup() error {
+	return os.RemoveAll(c.dir)
+}
+
+// write atomically replaces the timestamp file with the current settings. The caller must hold the mutex.
+func (c *Clock) write() error {
+	spec := fmt.Sprintf("%+.6f", c.offset.Seconds())
+	if c.speed != 1 {
+		spec += fmt.Sprintf(" x%g", c.speed)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, timestampFile+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.WriteString(spec + "\n"); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, timestampFile))
+}
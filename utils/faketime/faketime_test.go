@@ -0,0 +1,45 @@
+package faketime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	c, err := NewClock()
+	if err != nil {
+		t.Fatalf("NewClock() error = %v", err)
+	}
+	defer c.Cleanup()
+
+	read := func() string {
+		b, err := os.ReadFile(filepath.Join(c.Dir(), timestampFile))
+		if err != nil {
+			t.Fatalf("reading timestamp file failed: %v", err)
+		}
+		return string(b)
+	}
+
+	if got, want := read(), "+0.000000\n"; got != want {
+		t.Errorf("initial timestamp file = %q, want %q", got, want)
+	}
+
+	if err := c.Advance(90 * time.Minute); err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if err := c.SetSpeed(2); err != nil {
+		t.Fatalf("SetSpeed() error = %v", err)
+	}
+
+	if got, want := read(), "+5400.000000 x2\n"; got != want {
+		t.Errorf("timestamp file = %q, want %q", got, want)
+	}
+	if got, want := c.Offset(), 90*time.Minute; got != want {
+		t.Errorf("Offset() = %v, want %v", got, want)
+	}
+	if err := c.SetSpeed(0); err == nil {
+		t.Errorf("SetSpeed(0) should fail")
+	}
+}
@@ -2,36 +2,71 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils/pki"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 )
 
 type Icinga2Client struct {
 	http.Client
+
+	consoleSessionMutex sync.Mutex
+	consoleSession      string
+}
+
+// Icinga2ClientOption configures the TLS connections of the client created by NewIcinga2Client.
+type Icinga2ClientOption func(*tls.Config)
+
+// WithIcinga2ClientRootCA verifies the certificate of the Icinga 2 API against the given CA certificate. The
+// certificate must be issued for serverName. Without this option, the certificate is not verified at all.
+func WithIcinga2ClientRootCA(ca *x509.Certificate, serverName string) Icinga2ClientOption {
+	return func(c *tls.Config) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+		c.RootCAs = pool
+		c.ServerName = serverName
+		c.InsecureSkipVerify = false
+	}
 }
 
-func NewIcinga2Client(address string, username string, password string) *Icinga2Client {
+// WithIcinga2ClientCertificate authenticates using a TLS client certificate. If the username passed to
+// NewIcinga2Client is empty, no basic auth is used, so the ApiUser is identified by its client_cn attribute.
+func WithIcinga2ClientCertificate(cert *pki.CertificateWithKey) Icinga2ClientOption {
+	return func(c *tls.Config) {
+		c.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{cert.SignedCertificate},
+			PrivateKey:  cert.Key,
+		}}
+	}
+}
+
+func NewIcinga2Client(address string, username string, password string, options ...Icinga2ClientOption) *Icinga2Client {
+	t := &icinga2ClientHttpTransport{
+		host:     address,
+		username: username,
+		password: password,
+		tlsConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+	for _, option := range options {
+		option(t.tlsConfig)
+	}
+	t.wrappedTransport = &http.Transport{TLSClientConfig: t.tlsConfig}
+
 	return &Icinga2Client{
-		http.Client{
-			Transport: &icinga2ClientHttpTransport{
-				host:     address,
-				username: username,
-				password: password,
-				wrappedTransport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						// TODO(jb): certificate validation
-						InsecureSkipVerify: true,
-						//ServerName: ...,
-						//RootCAs:    ...,
-					},
-				},
-			},
+		Client: http.Client{
+			Transport: t,
 		},
 	}
 }
@@ -240,10 +275,155 @@ func (c *Icinga2Client) DeleteConfigPackage(t testing.TB, name string) {
 	}
 }
 
+// Eval evaluates an expression in the Icinga 2 DSL using the console API and returns its result as JSON.
+//
+// All calls on the same client share a console session, so variables assigned by one call are available in subsequent
+// ones. Use ResetConsoleSession to start a new session.
+//
+// Example usage:
+//
+//	result, err := c.Eval(ctx, `get_host("foo").last_check_result.state`)
+//
+// https://icinga.com/docs/icinga-2/latest/doc/12-icinga2-api/#console
+func (c *Icinga2Client) Eval(ctx context.Context, expr string) (json.RawMessage, error) {
+	c.consoleSessionMutex.Lock()
+	if c.consoleSession == "" {
+		c.consoleSession = RandomString(32)
+	}
+	session := c.consoleSession
+	c.consoleSessionMutex.Unlock()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"command":   expr,
+		"session":   session,
+		"sandboxed": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/console/execute-script", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.addJsonHeaders(req)
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var data struct {
+		Results []struct {
+			Code   float64         `json:"code"`
+			Status string          `json:"status"`
+			Result json.RawMessage `json:"result"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response to console request (HTTP %s): %w", res.Status, err)
+	}
+	if len(data.Results) != 1 {
+		return nil, fmt.Errorf("expected one result from console request (HTTP %s), got %d",
+			res.Status, len(data.Results))
+	}
+
+	result := data.Results[0]
+	if result.Code < 200 || result.Code > 299 {
+		return nil, fmt.Errorf("evaluating %q failed with code %v: %s", expr, result.Code, result.Status)
+	}
+
+	return result.Result, nil
+}
+
+// ResetConsoleSession makes subsequent calls to Eval use a new console session.
+func (c *Icinga2Client) ResetConsoleSession() {
+	c.consoleSessionMutex.Lock()
+	defer c.consoleSessionMutex.Unlock()
+
+	c.consoleSession = ""
+}
+
+// EvalInto evaluates an expression in the Icinga 2 DSL using Icinga2Client.Eval and unmarshals the result into T.
+//
+// Example usage:
+//
+//	state, err := utils.EvalInto[float64](ctx, c, `get_host("foo").state`)
+func EvalInto[T any](ctx context.Context, c *Icinga2Client, expr string) (T, error) {
+	var result T
+
+	raw, err := c.Eval(ctx, expr)
+	if err != nil {
+		return result, err
+	}
+
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return result, fmt.Errorf("failed to unmarshal result of %q into %T: %w", expr, result, err)
+	}
+
+	return result, nil
+}
+
+// Action executes an Icinga 2 API action, for example "process-check-result", with the given parameters. It fails if
+// the action matched no objects or failed for any of them.
+//
+// Example usage:
+//
+//	err := c.Action(ctx, "acknowledge-problem", map[string]interface{}{
+//		"type":    "Host",
+//		"host":    "foo",
+//		"author":  "icinga-testing",
+//		"comment": "ack",
+//	})
+//
+// https://icinga.com/docs/icinga-2/latest/doc/12-icinga2-api/#actions
+func (c *Icinga2Client) Action(ctx context.Context, action string, params map[string]interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/actions/"+action, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.addJsonHeaders(req)
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var data struct {
+		Results []struct {
+			Code   float64 `json:"code"`
+			Status string  `json:"status"`
+		} `json:"results"`
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return fmt.Errorf("failed to decode response to action %q (HTTP %s): %w", action, res.Status, err)
+	}
+	if len(data.Results) == 0 {
+		return fmt.Errorf("action %q matched no objects (HTTP %s): %s", action, res.Status, data.Status)
+	}
+
+	for _, result := range data.Results {
+		if result.Code < 200 || result.Code > 299 {
+			return fmt.Errorf("action %q failed with code %v: %s", action, result.Code, result.Status)
+		}
+	}
+
+	return nil
+}
+
 type icinga2ClientHttpTransport struct {
 	host             string
 	username         string
 	password         string
+	tlsConfig        *tls.Config
 	wrappedTransport http.RoundTripper
 }
 
@@ -251,6 +431,8 @@ func (t *icinga2ClientHttpTransport) RoundTrip(req *http.Request) (*http.Respons
 	req.Host = t.host
 	req.URL.Host = t.host
 	req.URL.Scheme = "https"
-	req.SetBasicAuth(t.username, t.password)
+	if t.username != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
 	return t.wrappedTransport.RoundTrip(req)
 }
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIcinga2ClientEval(t *testing.T) {
+	var sessions []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Command string `json:"command"`
+			Session string `json:"session"`
+		}
+		if r.URL.Path != "/v1/console/execute-script" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		sessions = append(sessions, req.Session)
+
+		if req.Command == "1 + 1" {
+			_, _ = w.Write([]byte(`{"results":[{"code":200,"status":"Executed successfully.","result":2}]}`))
+		} else {
+			_, _ = w.Write([]byte(`{"results":[{"code":500,"status":"Error: syntax error"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewIcinga2Client(strings.TrimPrefix(server.URL, "https://"), "root", "secret")
+
+	got, err := EvalInto[int](context.Background(), c, "1 + 1")
+	if err != nil {
+		t.Fatalf("EvalInto() error = %v", err)
+	}
+	if got != 2 {
+		t.Errorf("EvalInto() = %d, want 2", got)
+	}
+
+	if _, err := c.Eval(context.Background(), "1 +"); err == nil {
+		t.Errorf("Eval() of invalid expression should fail")
+	}
+
+	c.ResetConsoleSession()
+	if _, err := c.Eval(context.Background(), "1 + 1"); err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	if len(sessions) != 3 || sessions[0] == "" || sessions[0] != sessions[1] || sessions[1] == sessions[2] {
+		t.Errorf("unexpected console sessions %q", sessions)
+	}
+}
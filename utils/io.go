@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"sync"
 )
 
 // LineWriter implements io.WriteCloser and calls the given callback for every line written to it.
@@ -58,3 +59,50 @@ func (l *LineWriter) Close() error {
 	l.buf = nil
 	return nil
 }
+
+// LineRecorder records lines, for example the output of a container, so that they can be inspected later. It is safe
+// for concurrent use.
+type LineRecorder struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+// Add records a line.
+func (r *LineRecorder) Add(line []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lines = append(r.lines, string(line))
+}
+
+// Lines returns all lines recorded so far.
+func (r *LineRecorder) Lines() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string(nil), r.lines...)
+}
+
+// Since returns the lines recorded after the first n ones together with the total number of lines recorded so far.
+// Passing that number to the next call returns only the lines recorded in the meantime, which allows processing new
+// lines without copying all of them again.
+func (r *LineRecorder) Since(n int) ([]string, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if n >= len(r.lines) {
+		return nil, len(r.lines)
+	}
+	return append([]string(nil), r.lines[n:]...), len(r.lines)
+}
+
+// Last returns up to n of the most recently recorded lines.
+func (r *LineRecorder) Last(n int) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.lines) > n {
+		return append([]string(nil), r.lines[len(r.lines)-n:]...)
+	}
+	return append([]string(nil), r.lines...)
+}
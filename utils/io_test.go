@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineRecorderSince(t *testing.T) {
+	var r LineRecorder
+	r.Add([]byte("a"))
+	r.Add([]byte("b"))
+
+	lines, n := r.Since(0)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(lines, want) || n != 2 {
+		t.Errorf("Since(0) = %q, %d, want %q, 2", lines, n, want)
+	}
+
+	if lines, n := r.Since(n); lines != nil || n != 2 {
+		t.Errorf("Since(2) = %q, %d, want nil, 2", lines, n)
+	}
+
+	r.Add([]byte("c"))
+	if lines, n := r.Since(n); !reflect.DeepEqual(lines, []string{"c"}) || n != 3 {
+		t.Errorf("Since(2) = %q, %d, want [\"c\"], 3", lines, n)
+	}
+}
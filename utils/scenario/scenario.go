@@ -0,0 +1,290 @@
+// Package scenario runs scripted timelines of check results and other actions against hosts and services of an
+// Icinga 2 node and reports the exact timestamps used, so that tests can state precisely which history and SLA rows
+// they expect.
+//
+// Example usage:
+//
+//	report, err := scenario.Run(ctx, icinga2.ApiClient(), []scenario.Timeline{{
+//		Host:    "foo",
+//		Service: "bar",
+//		Steps: []scenario.Step{
+//			{At: 0, Action: scenario.CheckResult{ExitStatus: scenario.Ok}},
+//			{At: 2 * time.Second, Action: scenario.CheckResult{ExitStatus: scenario.Critical, Output: "x"}},
+//			{At: 5 * time.Second, Action: scenario.Acknowledge{Author: "test", Comment: "ack"}},
+//		},
+//	}})
+//	require.NoError(t, err)
+//
+//	criticalSince := report.For("foo", "bar")[1].Time
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-testing/utils"
+	"sort"
+	"time"
+)
+
+// Exit statuses of check results for services.
+const (
+	Ok       = 0
+	Warning  = 1
+	Critical = 2
+	Unknown  = 3
+)
+
+// Exit statuses of check results for hosts.
+const (
+	Up   = 0
+	Down = 1
+)
+
+// Action is something that happens to a host or service at a step of a timeline.
+type Action interface {
+	// apply returns the name and parameters of the Icinga 2 API action executed at time t. The parameters identifying
+	// the host or service are added by the caller.
+	apply(t time.Time) (string, map[string]interface{})
+}
+
+// CheckResult submits a passive check result using the process-check-result action. The time of the step is used as
+// execution start and end of the check result.
+type CheckResult struct {
+	ExitStatus      int
+	Output          string
+	PerformanceData []string
+}
+
+func (c CheckResult) apply(t time.Time) (string, map[string]interface{}) {
+	params := map[string]interface{}{
+		"exit_status":     c.ExitStatus,
+		"plugin_output":   c.Output,
+		"execution_start": unixFloat(t),
+		"execution_end":   unixFloat(t),
+	}
+	if len(c.PerformanceData) > 0 {
+		params["performance_data"] = c.PerformanceData
+	}
+	return "process-check-result", params
+}
+
+// Acknowledge acknowledges the current problem using the acknowledge-problem action. Icinga 2 uses the time it
+// receives the request as the time of the acknowledgement.
+type Acknowledge struct {
+	Author     string
+	Comment    string
+	Sticky     bool
+	Notify     bool
+	Persistent bool
+
+	// Expiry is the time after the step at which the acknowledgement expires. If 0, it does not expire.
+	Expiry time.Duration
+}
+
+func (a Acknowledge) apply(t time.Time) (string, map[string]interface{}) {
+	params := map[string]interface{}{
+		"author":     a.Author,
+		"comment":    a.Comment,
+		"sticky":     a.Sticky,
+		"notify":     a.Notify,
+		"persistent": a.Persistent,
+	}
+	if a.Expiry > 0 {
+		params["expiry"] = unixFloat(t.Add(a.Expiry))
+	}
+	return "acknowledge-problem", params
+}
+
+// RemoveAcknowledgement removes the acknowledgement using the remove-acknowledgement action.
+type RemoveAcknowledgement struct {
+	Author string
+}
+
+func (r RemoveAcknowledgement) apply(time.Time) (string, map[string]interface{}) {
+	params := map[string]interface{}{}
+	if r.Author != "" {
+		params["author"] = r.Author
+	}
+	return "remove-acknowledgement", params
+}
+
+// ScheduleDowntime schedules a downtime starting at the time of the step using the schedule-downtime action.
+type ScheduleDowntime struct {
+	Author  string
+	Comment string
+
+	// Duration is the time between the start and end time of the downtime.
+	Duration time.Duration
+
+	// Flexible makes the downtime flexible. It is triggered by a problem within its time window and then lasts for
+	// FlexibleDuration.
+	Flexible         bool
+	FlexibleDuration time.Duration
+}
+
+func (d ScheduleDowntime) apply(t time.Time) (string, map[string]interface{}) {
+	params := map[string]interface{}{
+		"author":     d.Author,
+		"comment":    d.Comment,
+		"start_time": unixFloat(t),
+		"end_time":   unixFloat(t.Add(d.Duration)),
+		"fixed":      !d.Flexible,
+	}
+	if d.Flexible {
+		params["duration"] = d.FlexibleDuration.Seconds()
+	}
+	return "schedule-downtime", params
+}
+
+// AddComment adds a comment using the add-comment action. Icinga 2 uses the time it receives the request as the entry
+// time of the comment.
+type AddComment struct {
+	Author  string
+	Comment string
+}
+
+func (c AddComment) apply(time.Time) (string, map[string]interface{}) {
+	return "add-comment", map[string]interface{}{
+		"author":  c.Author,
+		"comment": c.Comment,
+	}
+}
+
+// Step is an action executed at a given time relative to the start of the scenario.
+type Step struct {
+	At     time.Duration
+	Action Action
+}
+
+// Timeline is a sequence of steps for a host or, if Service is not empty, a service.
+type Timeline struct {
+	Host    string
+	Service string
+	Steps   []Step
+}
+
+// Executed records a step that was executed.
+type Executed struct {
+	Host    string
+	Service string
+	Step    Step
+
+	// Time is the timestamp passed to Icinga 2 for this step, or, for actions that do not take a timestamp, the time
+	// the request was sent.
+	Time time.Time
+}
+
+// Report lists all executed steps in the order they were executed.
+type Report []Executed
+
+// For returns the executed steps of the host or, if service is not empty, service in the order they were executed.
+func (r Report) For(host string, service string) []Executed {
+	var executed []Executed
+	for _, e := range r {
+		if e.Host == host && e.Service == service {
+			executed = append(executed, e)
+		}
+	}
+	return executed
+}
+
+type runner struct {
+	now func() time.Time
+}
+
+// Option configures Run.
+type Option func(*runner)
+
+// WithNow sets the function returning the current time as seen by Icinga 2. The steps are still scheduled in real
+// time. Defaults to time.Now. If the node runs with a fake clock that was only advanced, this can be:
+//
+//	scenario.WithNow(func() time.Time { return time.Now().Add(clock.Offset()) })
+func WithNow(now func() time.Time) Option {
+	return func(r *runner) {
+		r.now = now
+	}
+}
+
+// Run executes the steps of all timelines in the order of their time. Steps with the same time are executed in the
+// order of the timelines and steps. Run returns once the last step was executed and stops at the first failing step,
+// returning the steps executed until then.
+func Run(ctx context.Context, c *utils.Icinga2Client, timelines []Timeline, options ...Option) (Report, error) {
+	r := &runner{now: time.Now}
+	for _, option := range options {
+		option(r)
+	}
+
+	steps, err := plan(timelines)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	report := make(Report, 0, len(steps))
+	for _, step := range steps {
+		if d := time.Until(start.Add(step.Step.At)); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return report, ctx.Err()
+			}
+		}
+
+		step.Time = r.now()
+		action, params := step.Step.Action.apply(step.Time)
+		if step.Service == "" {
+			params["type"] = "Host"
+			params["host"] = step.Host
+		} else {
+			params["type"] = "Service"
+			params["service"] = step.Host + "!" + step.Service
+		}
+
+		if err := c.Action(ctx, action, params); err != nil {
+			return report, fmt.Errorf("step at %s for %q failed: %w",
+				step.Step.At, checkableName(step.Host, step.Service), err)
+		}
+
+		report = append(report, step)
+	}
+
+	return report, nil
+}
+
+// plan returns the steps of all timelines in the order they have to be executed.
+func plan(timelines []Timeline) ([]Executed, error) {
+	var steps []Executed
+	for _, timeline := range timelines {
+		if timeline.Host == "" {
+			return nil, errors.New("timeline without host")
+		}
+
+		for _, step := range timeline.Steps {
+			if step.Action == nil {
+				return nil, fmt.Errorf("step at %s for %q has no action",
+					step.At, checkableName(timeline.Host, timeline.Service))
+			}
+			steps = append(steps, Executed{Host: timeline.Host, Service: timeline.Service, Step: step})
+		}
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Step.At < steps[j].Step.At
+	})
+
+	return steps, nil
+}
+
+// checkableName returns the full name of a host or, if service is not empty, of a service.
+func checkableName(host string, service string) string {
+	if service == "" {
+		return host
+	}
+	return host + "!" + service
+}
+
+// unixFloat returns t as seconds since the epoch as used for timestamps by Icinga 2.
+func unixFloat(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,52 @@
+package scenario
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	ok := CheckResult{ExitStatus: Ok}
+	critical := CheckResult{ExitStatus: Critical}
+	ack := Acknowledge{Author: "a"}
+
+	steps, err := plan([]Timeline{
+		{Host: "h", Steps: []Step{{At: 0, Action: ok}, {At: 2 * time.Second, Action: critical}}},
+		{Host: "h", Service: "s", Steps: []Step{{At: 2 * time.Second, Action: ok}, {At: time.Second, Action: ack}}},
+	})
+	if err != nil {
+		t.Fatalf("plan() error = %v", err)
+	}
+
+	want := []Executed{
+		{Host: "h", Step: Step{At: 0, Action: ok}},
+		{Host: "h", Service: "s", Step: Step{At: time.Second, Action: ack}},
+		{Host: "h", Step: Step{At: 2 * time.Second, Action: critical}},
+		{Host: "h", Service: "s", Step: Step{At: 2 * time.Second, Action: ok}},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("plan() = %v, want %v", steps, want)
+	}
+}
+
+func TestPlanInvalid(t *testing.T) {
+	if _, err := plan([]Timeline{{Steps: []Step{{Action: CheckResult{}}}}}); err == nil {
+		t.Errorf("plan() without host succeeded, want error")
+	}
+	if _, err := plan([]Timeline{{Host: "h", Steps: []Step{{}}}}); err == nil {
+		t.Errorf("plan() without action succeeded, want error")
+	}
+}
+
+func TestCheckResultApply(t *testing.T) {
+	ts := time.Unix(1600000000, 500000000)
+
+	action, params := CheckResult{ExitStatus: Warning, Output: "w"}.apply(ts)
+	if action != "process-check-result" {
+		t.Errorf("apply() action = %q, want %q", action, "process-check-result")
+	}
+	if params["execution_start"] != 1600000000.5 || params["execution_end"] != 1600000000.5 {
+		t.Errorf("apply() params = %v, want execution_start and execution_end = 1600000000.5", params)
+	}
+}
@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Difference is a single difference between two schemas A and B.
+type Difference struct {
+	// Object identifies the differing object, for example "table host", "column host.name" or
+	// "index host.idx_host_name".
+	Object string
+
+	// A and B describe the object in both schemas. One of them is empty if the object only exists in the other one.
+	A string
+	B string
+}
+
+func (d Difference) String() string {
+	return d.Format("A", "B")
+}
+
+// Format returns a human-readable representation, referring to the schemas by the names a and b, for example "mysql"
+// and "pgsql".
+func (d Difference) Format(a string, b string) string {
+	switch {
+	case d.A == "":
+		return fmt.Sprintf("%s: only in %s: %s", d.Object, b, d.B)
+	case d.B == "":
+		return fmt.Sprintf("%s: only in %s: %s", d.Object, a, d.A)
+	default:
+		return fmt.Sprintf("%s: %s has %s, %s has %s", d.Object, a, d.A, b, d.B)
+	}
+}
+
+// Diff is the result of comparing two schemas, sorted by object.
+type Diff []Difference
+
+// Empty returns whether no differences were found.
+func (d Diff) Empty() bool {
+	return len(d) == 0
+}
+
+// String returns a human-readable representation with one difference per line.
+func (d Diff) String() string {
+	return d.Format("A", "B")
+}
+
+// Format returns a human-readable representation with one difference per line, see Difference.Format.
+func (d Diff) Format(a string, b string) string {
+	var sb strings.Builder
+	for _, difference := range d {
+		sb.WriteString(difference.Format(a, b))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Compute returns the differences between the schemas a and b.
+func Compute(a Schema, b Schema) Diff {
+	var diff Diff
+
+	for _, name := range keys(a.Tables, b.Tables) {
+		ta, inA := a.Tables[name]
+		tb, inB := b.Tables[name]
+		if !inA || !inB {
+			diff = append(diff, difference("table "+name, inA, "exists", inB, "exists"))
+			continue
+		}
+
+		for _, column := range keys(ta.Columns, tb.Columns) {
+			ca, inA := ta.Columns[column]
+			cb, inB := tb.Columns[column]
+			diff = appendIfDifferent(diff, difference("column "+name+"."+column, inA, ca.String(), inB, cb.String()))
+		}
+
+		for _, index := range keys(ta.Indexes, tb.Indexes) {
+			ia, inA := ta.Indexes[index]
+			ib, inB := tb.Indexes[index]
+			diff = appendIfDifferent(diff, difference("index "+name+"."+index, inA, ia.String(), inB, ib.String()))
+		}
+
+		for _, constraint := range keys(ta.Constraints, tb.Constraints) {
+			ca, inA := ta.Constraints[constraint]
+			cb, inB := tb.Constraints[constraint]
+			diff = appendIfDifferent(diff,
+				difference("constraint "+name+"."+constraint, inA, ca.String(), inB, cb.String()))
+		}
+	}
+
+	sort.SliceStable(diff, func(i, j int) bool {
+		return diff[i].Object < diff[j].Object
+	})
+
+	return diff
+}
+
+func (c Column) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.Type)
+	if c.BaseType != "" && c.BaseType != c.Type {
+		_, _ = fmt.Fprintf(&sb, " (domain of %s)", c.BaseType)
+	}
+	if c.Enum != nil && !strings.HasPrefix(c.Type, "enum(") {
+		_, _ = fmt.Fprintf(&sb, " (enum of %s)", strings.Join(c.Enum, ", "))
+	}
+	if !c.Nullable {
+		sb.WriteString(" NOT NULL")
+	}
+	if c.Default != nil {
+		_, _ = fmt.Fprintf(&sb, " DEFAULT %s", *c.Default)
+	}
+	if c.AutoIncrement {
+		sb.WriteString(" AUTO_INCREMENT")
+	}
+	return sb.String()
+}
+
+func (i Index) String() string {
+	prefix := ""
+	if i.Unique {
+		prefix = "UNIQUE "
+	}
+	return fmt.Sprintf("%s(%s)", prefix, strings.Join(i.Columns, ", "))
+}
+
+func (c Constraint) String() string {
+	s := fmt.Sprintf("%s (%s)", c.Type, strings.Join(c.Columns, ", "))
+	if c.ReferencedTable != "" {
+		s += fmt.Sprintf(" REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
+			c.ReferencedTable, strings.Join(c.ReferencedColumns, ", "), c.OnUpdate, c.OnDelete)
+	}
+	return s
+}
+
+// difference returns the Difference for an object described by a and b, which are only used if the object exists in
+// the respective schema.
+func difference(object string, inA bool, a string, inB bool, b string) Difference {
+	d := Difference{Object: object}
+	if inA {
+		d.A = a
+	}
+	if inB {
+		d.B = b
+	}
+	return d
+}
+
+// appendIfDifferent appends d to diff unless it describes an object that is equal in both schemas.
+func appendIfDifferent(diff Diff, d Difference) Diff {
+	if d.A == d.B {
+		return diff
+	}
+	return append(diff, d)
+}
+
+// keys returns the sorted union of the keys of a and b.
+func keys[V any](a map[string]V, b map[string]V) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		seen[k] = struct{}{}
+	}
+	for k := range b {
+		seen[k] = struct{}{}
+	}
+
+	result := make([]string, 0, len(seen))
+	for k := range seen {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	a := Schema{Tables: map[string]Table{
+		"host": {
+			Columns: map[string]Column{"id": {Type: "binary(20)"}, "name": {Type: "varchar(255)"}},
+			Indexes: map[string]Index{"PRIMARY": {Unique: true, Columns: []string{"id"}}},
+			Constraints: map[string]Constraint{
+				"PRIMARY": {Type: "PRIMARY KEY", Columns: []string{"id"}},
+			},
+		},
+		"icingadb_schema": {},
+	}}
+	b := Schema{Tables: map[string]Table{
+		"host": {
+			Columns: map[string]Column{"id": {Type: "binary(20)"}, "name": {Type: "text"}},
+			Indexes: map[string]Index{
+				"PRIMARY":       {Unique: true, Columns: []string{"id"}},
+				"idx_host_name": {Columns: []string{"name"}},
+			},
+			Constraints: map[string]Constraint{
+				"PRIMARY": {Type: "PRIMARY KEY", Columns: []string{"id"}},
+			},
+		},
+	}}
+
+	want := Diff{
+		{Object: "column host.name", A: "varchar(255) NOT NULL", B: "text NOT NULL"},
+		{Object: "index host.idx_host_name", B: "(name)"},
+		{Object: "table icingadb_schema", A: "exists"},
+	}
+	if got := Compute(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compute() = %v, want %v", got, want)
+	}
+
+	if got := Compute(a, a); !got.Empty() {
+		t.Errorf("Compute() = %v, want no differences", got)
+	}
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// readMysql reads the schema of the current database from information_schema.
+func readMysql(ctx context.Context, db *sql.DB, s *Schema) error {
+	err := query(ctx, db, `SELECT table_name, column_name, column_type, is_nullable, column_default, extra
+		FROM information_schema.columns WHERE table_schema = DATABASE()`, func(rows *sql.Rows) error {
+		var table, column, nullable, extra string
+		var def sql.NullString
+		var c Column
+		if err := rows.Scan(&table, &column, &c.Type, &nullable, &def, &extra); err != nil {
+			return err
+		}
+		c.BaseType = c.Type
+		c.Nullable = nullable == "YES"
+		if def.Valid {
+			c.Default = &def.String
+		}
+		c.AutoIncrement = strings.Contains(strings.ToLower(extra), "auto_increment")
+		c.Enum = parseMysqlEnum(c.Type)
+		s.table(table).Columns[column] = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query(ctx, db, `SELECT table_name, index_name, non_unique, COALESCE(column_name, '(expression)')
+		FROM information_schema.statistics WHERE table_schema = DATABASE()
+		ORDER BY table_name, index_name, seq_in_index`, func(rows *sql.Rows) error {
+		var table, index, column string
+		var nonUnique bool
+		if err := rows.Scan(&table, &index, &nonUnique, &column); err != nil {
+			return err
+		}
+		t := s.table(table)
+		i := t.Indexes[index]
+		i.Unique = !nonUnique
+		i.Columns = append(i.Columns, column)
+		t.Indexes[index] = i
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return query(ctx, db, `SELECT tc.table_name, tc.constraint_name, tc.constraint_type, kcu.column_name,
+			kcu.referenced_table_name, kcu.referenced_column_name, rc.update_rule, rc.delete_rule
+		FROM information_schema.table_constraints tc
+		LEFT JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.table_name = tc.table_name AND kcu.constraint_name = tc.constraint_name
+		LEFT JOIN information_schema.referential_constraints rc ON rc.constraint_schema = tc.constraint_schema
+			AND rc.table_name = tc.table_name AND rc.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = DATABASE()
+		ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`, func(rows *sql.Rows) error {
+		var table, constraint, typ string
+		var column, refTable, refColumn, onUpdate, onDelete sql.NullString
+		err := rows.Scan(&table, &constraint, &typ, &column, &refTable, &refColumn, &onUpdate, &onDelete)
+		if err != nil {
+			return err
+		}
+		t := s.table(table)
+		c := t.Constraints[constraint]
+		c.Type = typ
+		if column.Valid {
+			c.Columns = append(c.Columns, column.String)
+		}
+		if refColumn.Valid {
+			c.ReferencedTable = refTable.String
+			c.ReferencedColumns = append(c.ReferencedColumns, refColumn.String)
+			c.OnUpdate = onUpdate.String
+			c.OnDelete = onDelete.String
+		}
+		t.Constraints[constraint] = c
+		return nil
+	})
+}
+
+// parseMysqlEnum returns the values of a column type like "enum('n','y')", or nil if it is not an enum type.
+func parseMysqlEnum(typ string) []string {
+	if !strings.HasPrefix(typ, "enum(") || !strings.HasSuffix(typ, ")") {
+		return nil
+	}
+
+	var values []string
+	var value strings.Builder
+	inValue := false
+	list := typ[len("enum(") : len(typ)-1]
+	for i := 0; i < len(list); i++ {
+		switch ch := list[i]; {
+		case ch == '\'' && inValue && i+1 < len(list) && list[i+1] == '\'':
+			// Quotes within values are escaped by doubling them.
+			value.WriteByte('\'')
+			i++
+		case ch == '\'':
+			if inValue {
+				values = append(values, value.String())
+				value.Reset()
+			}
+			inValue = !inValue
+		case inValue:
+			value.WriteByte(ch)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// normalizedPrimaryKey is the name used for primary key constraints and their indexes by Normalize. It is the name
+// MySQL always uses for them.
+const normalizedPrimaryKey = "PRIMARY"
+
+var (
+	// typeLength matches the length or precision of a type, for example "(255)" or "(10,2)".
+	typeLength = regexp.MustCompile(`\(([\d, ]+)\)`)
+
+	// postgresqlCast matches a type cast PostgreSQL adds to default values, for example "::character varying".
+	postgresqlCast = regexp.MustCompile(`::[a-z][a-z0-9_ ]*(\[\])?(\([\d, ]+\))?$`)
+)
+
+// normalizedTypes maps the type names of MySQL and PostgreSQL without length and modifiers to a common name.
+var normalizedTypes = map[string]string{
+	"tinyint":           "smallint",
+	"smallint":          "smallint",
+	"mediumint":         "integer",
+	"int":               "integer",
+	"integer":           "integer",
+	"bigint":            "bigint",
+	"float":             "float",
+	"double":            "float",
+	"real":              "float",
+	"double precision":  "float",
+	"decimal":           "decimal",
+	"numeric":           "decimal",
+	"char":              "string",
+	"character":         "string",
+	"varchar":           "string",
+	"character varying": "string",
+	"tinytext":          "text",
+	"text":              "text",
+	"mediumtext":        "text",
+	"longtext":          "text",
+	"citext":            "text",
+	"binary":            "binary",
+	"varbinary":         "binary",
+	"tinyblob":          "binary",
+	"blob":              "binary",
+	"mediumblob":        "binary",
+	"longblob":          "binary",
+	"bytea":             "binary",
+	"bool":              "boolean",
+	"boolean":           "boolean",
+}
+
+// Normalize returns a copy of the schema in which differences between MySQL and PostgreSQL that do not change the
+// meaning of the schema are removed, so that the schemas of both can be compared:
+//
+//   - Column types are replaced by a common name based on the base type, for example "string(255)" for both
+//     "varchar(255)" and "character varying(255)". Display widths, "unsigned" and the length of binary types, which
+//     PostgreSQL does not support, are removed. Enum columns get the type "enum(value, ...)".
+//   - Quotes and casts are removed from default values. Defaults of auto increment columns are removed.
+//   - Primary key constraints and their indexes are named "PRIMARY".
+//   - Indexes MySQL creates implicitly for foreign keys are removed.
+//   - CHECK constraints are removed, as their expressions are not read and MySQL does not report their columns.
+//   - The referential action RESTRICT is replaced by NO ACTION, which is equivalent in MySQL.
+//   - Enum types are removed from Schema.Enums, as they are part of the column types now.
+func (s Schema) Normalize() Schema {
+	n := Schema{Tables: make(map[string]Table, len(s.Tables)), Enums: map[string][]string{}}
+
+	for name, t := range s.Tables {
+		nt := Table{
+			Columns:     make(map[string]Column, len(t.Columns)),
+			Indexes:     make(map[string]Index, len(t.Indexes)),
+			Constraints: make(map[string]Constraint, len(t.Constraints)),
+		}
+
+		for column, c := range t.Columns {
+			nt.Columns[column] = normalizeColumn(c)
+		}
+
+		renamed := make(map[string]string)
+		foreignKeys := make(map[string]struct{})
+		for constraint, c := range t.Constraints {
+			switch c.Type {
+			case "CHECK":
+				continue
+			case "PRIMARY KEY":
+				renamed[constraint] = normalizedPrimaryKey
+				constraint = normalizedPrimaryKey
+			case "FOREIGN KEY":
+				foreignKeys[constraint] = struct{}{}
+			}
+
+			if c.OnUpdate == "RESTRICT" {
+				c.OnUpdate = "NO ACTION"
+			}
+			if c.OnDelete == "RESTRICT" {
+				c.OnDelete = "NO ACTION"
+			}
+			nt.Constraints[constraint] = c
+		}
+
+		for index, i := range t.Indexes {
+			if _, ok := foreignKeys[index]; ok {
+				continue
+			}
+			if newName, ok := renamed[index]; ok {
+				index = newName
+			}
+			nt.Indexes[index] = i
+		}
+
+		n.Tables[name] = nt
+	}
+
+	return n
+}
+
+// normalizeColumn returns the normalized column as described for Schema.Normalize.
+func normalizeColumn(c Column) Column {
+	n := Column{Nullable: c.Nullable, AutoIncrement: c.AutoIncrement, Enum: c.Enum}
+
+	if c.Enum != nil {
+		n.Type = fmt.Sprintf("enum(%s)", strings.Join(c.Enum, ", "))
+	} else {
+		n.Type = normalizeType(c.BaseType)
+	}
+	n.BaseType = n.Type
+
+	if c.Default != nil && !c.AutoIncrement {
+		def := normalizeDefault(*c.Default)
+		// MariaDB reports a NULL default as the string "NULL", while MySQL and PostgreSQL report no default.
+		if def != "NULL" {
+			n.Default = &def
+		}
+	}
+
+	return n
+}
+
+// normalizeType returns the common name for a MySQL or PostgreSQL type, for example "string(255)" for
+// "varchar(255)". Unknown types are returned in lower case.
+func normalizeType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	typ = strings.TrimSpace(strings.TrimSuffix(typ, "unsigned"))
+
+	length := ""
+	if match := typeLength.FindStringSubmatchIndex(typ); match != nil {
+		length = strings.ReplaceAll(typ[match[2]:match[3]], " ", "")
+		typ = strings.TrimSpace(typ[:match[0]] + typ[match[1]:])
+	}
+
+	name, ok := normalizedTypes[typ]
+	if !ok {
+		name = typ
+	}
+
+	switch name {
+	case "string", "decimal":
+		if length != "" {
+			return name + "(" + length + ")"
+		}
+	}
+
+	return name
+}
+
+// normalizeDefault removes quotes and PostgreSQL type casts from a default value, for example "n" for "'n'::boolenum".
+func normalizeDefault(def string) string {
+	def = strings.TrimSpace(def)
+	for {
+		stripped := postgresqlCast.ReplaceAllString(def, "")
+		if stripped == def {
+			break
+		}
+		def = stripped
+	}
+
+	if len(def) >= 2 && def[0] == '\'' && def[len(def)-1] == '\'' {
+		def = strings.ReplaceAll(def[1:len(def)-1], "''", "'")
+	}
+
+	return def
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeType(t *testing.T) {
+	tests := map[string]string{
+		"varchar(255)":           "string(255)",
+		"character varying(255)": "string(255)",
+		"int(10) unsigned":       "integer",
+		"tinyint unsigned":       "smallint",
+		"bigint":                 "bigint",
+		"binary(20)":             "binary",
+		"bytea":                  "binary",
+		"mediumtext":             "text",
+		"citext":                 "text",
+		"decimal(10, 2)":         "decimal(10,2)",
+		"numeric(10,2)":          "decimal(10,2)",
+		"double precision":       "float",
+		"json":                   "json",
+	}
+
+	for typ, want := range tests {
+		if got := normalizeType(typ); got != want {
+			t.Errorf("normalizeType(%q) = %q, want %q", typ, got, want)
+		}
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	tests := map[string]string{
+		"'n'::boolenum":                   "n",
+		"'it''s'::character varying(255)": "it's",
+		"0":                               "0",
+		"n":                               "n",
+		"'{}'::text[]":                    "{}",
+		"'x'::character varying::text":    "x",
+	}
+
+	for def, want := range tests {
+		if got := normalizeDefault(def); got != want {
+			t.Errorf("normalizeDefault(%q) = %q, want %q", def, got, want)
+		}
+	}
+}
+
+func TestParseMysqlEnum(t *testing.T) {
+	if got, want := parseMysqlEnum("enum('n','y','it''s')"), []string{"n", "y", "it's"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMysqlEnum() = %q, want %q", got, want)
+	}
+	if got := parseMysqlEnum("varchar(255)"); got != nil {
+		t.Errorf("parseMysqlEnum() = %q, want nil", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	n := "n"
+	quotedN := "'n'::boolenum"
+	mysql := Schema{Tables: map[string]Table{
+		"host": {
+			Columns: map[string]Column{
+				"id":   {Type: "binary(20)", BaseType: "binary(20)"},
+				"name": {Type: "varchar(255)", BaseType: "varchar(255)"},
+				"is_volatile": {
+					Type: "enum('n','y')", BaseType: "enum('n','y')", Default: &n, Enum: []string{"n", "y"},
+				},
+				"environment_id": {Type: "binary(20)", BaseType: "binary(20)", Nullable: true},
+			},
+			Indexes: map[string]Index{
+				"PRIMARY":             {Unique: true, Columns: []string{"id"}},
+				"fk_host_environment": {Columns: []string{"environment_id"}},
+			},
+			Constraints: map[string]Constraint{
+				"PRIMARY": {Type: "PRIMARY KEY", Columns: []string{"id"}},
+				"fk_host_environment": {
+					Type: "FOREIGN KEY", Columns: []string{"environment_id"}, ReferencedTable: "environment",
+					ReferencedColumns: []string{"id"}, OnUpdate: "RESTRICT", OnDelete: "CASCADE",
+				},
+			},
+		},
+	}}
+	pgsql := Schema{Tables: map[string]Table{
+		"host": {
+			Columns: map[string]Column{
+				"id":   {Type: "bytea20", BaseType: "bytea"},
+				"name": {Type: "character varying(255)", BaseType: "character varying(255)"},
+				"is_volatile": {
+					Type: "boolenum", BaseType: "boolenum", Default: &quotedN, Enum: []string{"n", "y"},
+				},
+				"environment_id": {Type: "bytea20", BaseType: "bytea", Nullable: true},
+			},
+			Indexes: map[string]Index{
+				"pk_host": {Unique: true, Columns: []string{"id"}},
+			},
+			Constraints: map[string]Constraint{
+				"pk_host": {Type: "PRIMARY KEY", Columns: []string{"id"}},
+				"fk_host_environment": {
+					Type: "FOREIGN KEY", Columns: []string{"environment_id"}, ReferencedTable: "environment",
+					ReferencedColumns: []string{"id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE",
+				},
+				"host_name_check": {Type: "CHECK", Columns: []string{"name"}},
+			},
+		},
+	}, Enums: map[string][]string{"boolenum": {"n", "y"}}}
+
+	if diff := Compute(mysql, pgsql); diff.Empty() {
+		t.Errorf("Compute() = no differences, want differences without normalization")
+	}
+	if diff := Compute(mysql.Normalize(), pgsql.Normalize()); !diff.Empty() {
+		t.Errorf("Compute() after Normalize() = %v, want no differences", diff.Format("mysql", "pgsql"))
+	}
+}
@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// postgresqlConstraintTypes maps pg_constraint.contype to the constraint types used by MySQL's information_schema.
+var postgresqlConstraintTypes = map[string]string{
+	"p": "PRIMARY KEY",
+	"u": "UNIQUE",
+	"f": "FOREIGN KEY",
+	"c": "CHECK",
+	"t": "TRIGGER",
+	"x": "EXCLUDE",
+}
+
+// postgresqlReferentialActions maps pg_constraint.confupdtype and confdeltype to the actions used by MySQL's
+// information_schema.
+var postgresqlReferentialActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// readPostgresql reads the schema of the current schema from pg_catalog, which contains more details than
+// information_schema, for example the indexes.
+func readPostgresql(ctx context.Context, db *sql.DB, s *Schema) error {
+	err := query(ctx, db, `SELECT t.typname, e.enumlabel
+		FROM pg_enum e
+		JOIN pg_type t ON t.oid = e.enumtypid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = current_schema()
+		ORDER BY t.typname, e.enumsortorder`, func(rows *sql.Rows) error {
+		var typ, label string
+		if err := rows.Scan(&typ, &label); err != nil {
+			return err
+		}
+		s.Enums[typ] = append(s.Enums[typ], label)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = query(ctx, db, `SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod),
+			CASE WHEN ty.typtype = 'd' THEN format_type(ty.typbasetype, ty.typtypmod)
+				ELSE format_type(a.atttypid, a.atttypmod) END,
+			NOT a.attnotnull, pg_get_expr(d.adbin, d.adrelid), a.attidentity <> ''
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_type ty ON ty.oid = a.atttypid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped`,
+		func(rows *sql.Rows) error {
+			var table, column string
+			var def sql.NullString
+			var c Column
+			err := rows.Scan(&table, &column, &c.Type, &c.BaseType, &c.Nullable, &def, &c.AutoIncrement)
+			if err != nil {
+				return err
+			}
+			if def.Valid {
+				c.Default = &def.String
+				// Serial columns are implemented using a default value taken from a sequence.
+				c.AutoIncrement = c.AutoIncrement || strings.HasPrefix(def.String, "nextval(")
+			}
+			s.table(table).Columns[column] = c
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	// Enum values are assigned after reading all enum types, as the columns only reference them by name.
+	for _, t := range s.Tables {
+		for name, c := range t.Columns {
+			if values, ok := s.Enums[c.BaseType]; ok {
+				c.Enum = values
+				t.Columns[name] = c
+			}
+		}
+	}
+
+	err = query(ctx, db, `SELECT t.relname, i.relname, ix.indisunique, COALESCE(a.attname, '(expression)')
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = current_schema()
+		ORDER BY t.relname, i.relname, k.ord`, func(rows *sql.Rows) error {
+		var table, index, column string
+		var unique bool
+		if err := rows.Scan(&table, &index, &unique, &column); err != nil {
+			return err
+		}
+		t := s.table(table)
+		i := t.Indexes[index]
+		i.Unique = unique
+		i.Columns = append(i.Columns, column)
+		t.Indexes[index] = i
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// NOT NULL constraints, which PostgreSQL 18+ stores in pg_constraint, are skipped as the nullability of columns is
+	// already read from pg_attribute.
+	return query(ctx, db, `SELECT t.relname, con.conname, con.contype::text, a.attname,
+			rt.relname, ra.attname, con.confupdtype::text, con.confdeltype::text
+		FROM pg_constraint con
+		JOIN pg_class t ON t.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		LEFT JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord) ON true
+		LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		LEFT JOIN pg_class rt ON rt.oid = con.confrelid
+		LEFT JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refattnum
+		WHERE n.nspname = current_schema() AND con.contype <> 'n'
+		ORDER BY t.relname, con.conname, k.ord`, func(rows *sql.Rows) error {
+		var r postgresqlConstraintRow
+		err := rows.Scan(&r.table, &r.constraint, &r.contype, &r.column, &r.refTable, &r.refColumn, &r.onUpdate,
+			&r.onDelete)
+		if err != nil {
+			return err
+		}
+		return s.addPostgresqlConstraint(r)
+	})
+}
+
+// postgresqlConstraintRow is a row of the pg_constraint query in readPostgresql, one per constraint column.
+type postgresqlConstraintRow struct {
+	table, constraint, contype, onUpdate, onDelete string
+	column, refTable, refColumn                    sql.NullString
+}
+
+// addPostgresqlConstraint adds a column of a constraint read from pg_constraint to s.
+func (s *Schema) addPostgresqlConstraint(r postgresqlConstraintRow) error {
+	if r.contype == "n" {
+		// NOT NULL constraints are represented by Column.Nullable.
+		return nil
+	}
+
+	typ, ok := postgresqlConstraintTypes[r.contype]
+	if !ok {
+		return fmt.Errorf("unknown constraint type %q of constraint %q on table %q", r.contype, r.constraint, r.table)
+	}
+	t := s.table(r.table)
+	c := t.Constraints[r.constraint]
+	c.Type = typ
+	if r.column.Valid {
+		c.Columns = append(c.Columns, r.column.String)
+	}
+	if r.refColumn.Valid {
+		c.ReferencedTable = r.refTable.String
+		c.ReferencedColumns = append(c.ReferencedColumns, r.refColumn.String)
+		c.OnUpdate = postgresqlReferentialActions[r.onUpdate]
+		c.OnDelete = postgresqlReferentialActions[r.onDelete]
+	}
+	t.Constraints[r.constraint] = c
+	return nil
+}
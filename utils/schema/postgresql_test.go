@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestAddPostgresqlConstraint(t *testing.T) {
+	s := Schema{Tables: make(map[string]Table)}
+	rows := []postgresqlConstraintRow{
+		{table: "host", constraint: "pk_host", contype: "p", column: sql.NullString{String: "id", Valid: true}},
+		// PostgreSQL 18+ reports NOT NULL constraints, which have to be ignored.
+		{table: "host", constraint: "host_name_not_null", contype: "n",
+			column: sql.NullString{String: "name", Valid: true}},
+		{table: "host", constraint: "fk_host_env", contype: "f", onUpdate: "a", onDelete: "c",
+			column:    sql.NullString{String: "environment_id", Valid: true},
+			refTable:  sql.NullString{String: "environment", Valid: true},
+			refColumn: sql.NullString{String: "id", Valid: true}},
+	}
+	for _, r := range rows {
+		if err := s.addPostgresqlConstraint(r); err != nil {
+			t.Fatalf("addPostgresqlConstraint(%+v) = %v, want nil", r, err)
+		}
+	}
+
+	want := map[string]Constraint{
+		"pk_host": {Type: "PRIMARY KEY", Columns: []string{"id"}},
+		"fk_host_env": {Type: "FOREIGN KEY", Columns: []string{"environment_id"}, ReferencedTable: "environment",
+			ReferencedColumns: []string{"id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"},
+	}
+	if got := s.Tables["host"].Constraints; !reflect.DeepEqual(got, want) {
+		t.Errorf("constraints = %+v, want %+v", got, want)
+	}
+
+	unknown := postgresqlConstraintRow{table: "host", constraint: "x", contype: "?"}
+	if err := s.addPostgresqlConstraint(unknown); err == nil {
+		t.Errorf("addPostgresqlConstraint() with unknown type = nil, want error")
+	}
+}
@@ -0,0 +1,180 @@
+// Package schema reads the schema of a relational database into a Go model that can be compared, for example to check
+// that applying the Icinga DB schema upgrades results in the same schema as importing the full schema, or that the
+// MySQL and PostgreSQL schemas are equivalent (see Schema.Normalize).
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Database is the part of services.RelationalDatabase needed to read its schema.
+type Database interface {
+	Driver() string
+	DSN() string
+}
+
+// Schema is the schema of a database, mapping table names to tables.
+type Schema struct {
+	Tables map[string]Table
+
+	// Enums maps the names of PostgreSQL enum types to their values in order. MySQL has no named enum types, the
+	// values of its enum columns are only part of the column type.
+	Enums map[string][]string
+}
+
+// Table is a table of a Schema.
+type Table struct {
+	Columns     map[string]Column
+	Indexes     map[string]Index
+	Constraints map[string]Constraint
+}
+
+// Column is a column of a Table.
+type Column struct {
+	// Type is the column type as reported by the database, for example "varchar(255)" or "character varying(255)".
+	Type string
+
+	// BaseType is the type underlying a PostgreSQL domain, for example "bytea" for a domain defined as bytea. It equals
+	// Type for all other columns.
+	BaseType string
+
+	Nullable bool
+
+	// Default is the default value expression as reported by the database, or nil if the column has no default.
+	Default *string
+
+	// AutoIncrement is set for MySQL AUTO_INCREMENT columns as well as for PostgreSQL serial and identity columns.
+	AutoIncrement bool
+
+	// Enum contains the allowed values in order if the column is of an enum type.
+	Enum []string
+}
+
+// Index is an index of a Table.
+type Index struct {
+	Unique bool
+
+	// Columns are the indexed columns in order. Expressions are represented as "(expression)".
+	Columns []string
+}
+
+// Constraint is a constraint of a Table.
+type Constraint struct {
+	// Type is the type of the constraint, for example "PRIMARY KEY", "UNIQUE", "FOREIGN KEY" or "CHECK".
+	Type string
+
+	// Columns are the columns the constraint applies to in order.
+	Columns []string
+
+	// ReferencedTable and ReferencedColumns are the table and columns referenced by a foreign key.
+	ReferencedTable   string
+	ReferencedColumns []string
+
+	// OnUpdate and OnDelete are the referential actions of a foreign key, for example "CASCADE" or "NO ACTION".
+	OnUpdate string
+	OnDelete string
+}
+
+// Read reads the schema of the tables in the database db is connected to, i.e. the current database for MySQL and the
+// current schema for PostgreSQL.
+func Read(ctx context.Context, db Database) (Schema, error) {
+	conn, err := sql.Open(db.Driver(), db.DSN())
+	if err != nil {
+		return Schema{}, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	s := Schema{Tables: make(map[string]Table), Enums: make(map[string][]string)}
+	switch db.Driver() {
+	case "mysql":
+		err = readMysql(ctx, conn, &s)
+	case "postgres":
+		err = readPostgresql(ctx, conn, &s)
+	default:
+		err = fmt.Errorf("unsupported database driver %q", db.Driver())
+	}
+	if err != nil {
+		return Schema{}, err
+	}
+
+	return s, nil
+}
+
+// Option configures Compare.
+type Option func(*compareOptions)
+
+type compareOptions struct {
+	normalize bool
+}
+
+// WithNormalization makes Compare normalize both schemas before comparing them, see Schema.Normalize. This allows
+// checking that a MySQL and a PostgreSQL schema are equivalent.
+func WithNormalization() Option {
+	return func(o *compareOptions) {
+		o.normalize = true
+	}
+}
+
+// Compare reads the schemas of a and b and returns their differences.
+//
+// Example usage:
+//
+//	diff, err := schema.Compare(context.Background(), mysql, pgsql, schema.WithNormalization())
+//	require.NoError(t, err)
+//	require.True(t, diff.Empty(), diff.Format("mysql", "pgsql"))
+func Compare(ctx context.Context, a Database, b Database, options ...Option) (Diff, error) {
+	o := &compareOptions{}
+	for _, option := range options {
+		option(o)
+	}
+
+	schemaA, err := Read(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaB, err := Read(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.normalize {
+		schemaA = schemaA.Normalize()
+		schemaB = schemaB.Normalize()
+	}
+
+	return Compute(schemaA, schemaB), nil
+}
+
+// table returns the table with the given name, adding it to s if it does not exist yet.
+func (s *Schema) table(name string) Table {
+	t, ok := s.Tables[name]
+	if !ok {
+		t = Table{
+			Columns:     make(map[string]Column),
+			Indexes:     make(map[string]Index),
+			Constraints: make(map[string]Constraint),
+		}
+		s.Tables[name] = t
+	}
+	return t
+}
+
+// query runs a query and calls f for each row, which has to scan the row itself.
+func query(ctx context.Context, db *sql.DB, q string, f func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to query schema: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		if err := f(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Version is a version number of a service, for example 2.14.0 for Icinga 2.
+type Version struct {
+	// Raw is the version string as reported by the service, for example "r2.14.0-1" or "10.11.2-MariaDB-1:10.11.2".
+	Raw string
+
+	// Numbers are the dot-separated numeric components of the version, for example [2 14 0].
+	Numbers []int
+}
+
+var versionRegexp = regexp.MustCompile(`\d+(?:\.\d+)*`)
+
+// ParseVersion parses the first dot-separated sequence of numbers within s as a version, ignoring prefixes like "v" or
+// "r" and suffixes like "-1" or "-MariaDB".
+func ParseVersion(s string) (Version, error) {
+	match := versionRegexp.FindString(s)
+	if match == "" {
+		return Version{}, fmt.Errorf("no version number found in %q", s)
+	}
+
+	var numbers []int
+	for _, part := range strings.Split(match, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version number %q in %q: %w", match, s, err)
+		}
+		numbers = append(numbers, n)
+	}
+
+	return Version{Raw: s, Numbers: numbers}, nil
+}
+
+// String returns the raw version string.
+func (v Version) String() string {
+	return v.Raw
+}
+
+// Compare returns -1, 0 or 1 if v is older, equal or newer than other. Missing components are treated as 0, so 2.14
+// and 2.14.0 are equal.
+func (v Version) Compare(other Version) int {
+	for i := 0; i < len(v.Numbers) || i < len(other.Numbers); i++ {
+		var a, b int
+		if i < len(v.Numbers) {
+			a = v.Numbers[i]
+		}
+		if i < len(other.Numbers) {
+			b = other.Numbers[i]
+		}
+
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// Satisfies returns whether v satisfies a constraint like ">= 2.14". Multiple constraints can be separated by commas,
+// all of them must be satisfied, for example ">= 2.13, < 2.15". Supported operators are =, ==, !=, <, <=, > and >=.
+func (v Version) Satisfies(constraint string) (bool, error) {
+	for _, c := range strings.Split(constraint, ",") {
+		c = strings.TrimSpace(c)
+
+		op := strings.TrimRight(c, " 0123456789.")
+		other, err := ParseVersion(strings.TrimSpace(strings.TrimPrefix(c, op)))
+		if err != nil {
+			return false, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+		}
+
+		cmp := v.Compare(other)
+		var ok bool
+		switch strings.TrimSpace(op) {
+		case "=", "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		default:
+			return false, fmt.Errorf("invalid operator %q in version constraint %q", op, constraint)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// RequireVersion skips the test if the version of a service does not satisfy the given constraint, see
+// Version.Satisfies. If the constraint is invalid, the test fails instead.
+//
+// The service handles provide a RequireVersion method using this function, for example:
+//
+//	i.RequireVersion(t, ">= 2.14")
+func RequireVersion(t testing.TB, service string, version Version, constraint string) {
+	t.Helper()
+
+	ok, err := version.Satisfies(constraint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Skipf("%s version %s does not satisfy %q", service, version, constraint)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"r2.14.0-1", []int{2, 14, 0}},
+		{"v1.1.1", []int{1, 1, 1}},
+		{"10.11.2-MariaDB-1:10.11.2+maria~ubu2204", []int{10, 11, 2}},
+		{"16.1 (Debian 16.1-1.pgdg120+1)", []int{16, 1}},
+		{"7", []int{7}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseVersion(test.in)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) error = %v", test.in, err)
+		} else if !reflect.DeepEqual(got.Numbers, test.want) {
+			t.Errorf("ParseVersion(%q) = %v, want %v", test.in, got.Numbers, test.want)
+		}
+	}
+
+	if _, err := ParseVersion("edge"); err == nil {
+		t.Errorf("ParseVersion(%q) succeeded, want error", "edge")
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"2.14.0", ">= 2.14", true},
+		{"2.13.9", ">= 2.14", false},
+		{"2.14", "== 2.14.0", true},
+		{"2.14.1", "= 2.14.0", false},
+		{"2.14.1", "!= 2.14.0", true},
+		{"2.14.1", ">= 2.13, < 2.15", true},
+		{"2.15.0", ">= 2.13, < 2.15", false},
+		{"2.14.0", "<= 2.14", true},
+		{"2.14.0", ">2.13", true},
+	}
+
+	for _, test := range tests {
+		v, err := ParseVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := v.Satisfies(test.constraint)
+		if err != nil {
+			t.Errorf("Version(%q).Satisfies(%q) error = %v", test.version, test.constraint, err)
+		} else if got != test.want {
+			t.Errorf("Version(%q).Satisfies(%q) = %v, want %v", test.version, test.constraint, got, test.want)
+		}
+	}
+
+	for _, constraint := range []string{"~> 2.14", ">= edge", "2.14"} {
+		if _, err := (Version{Numbers: []int{2, 14}}).Satisfies(constraint); err == nil {
+			t.Errorf("Satisfies(%q) succeeded, want error", constraint)
+		}
+	}
+}